@@ -0,0 +1,199 @@
+package nest
+
+import (
+	"sort"
+	"time"
+)
+
+// AlarmEventType represents what happened to a smoke or CO alarm
+type AlarmEventType string
+
+const (
+	// AlarmRaised is emitted when an alarm leaves the ok state
+	AlarmRaised AlarmEventType = "raised"
+	// AlarmEscalated is emitted when an alarm goes from warning to emergency
+	AlarmEscalated AlarmEventType = "escalated"
+	// AlarmDeescalated is emitted when an alarm goes from emergency back to warning
+	AlarmDeescalated AlarmEventType = "deescalated"
+	// AlarmCleared is emitted when an alarm returns to the ok state, or its device goes away while it is raised
+	AlarmCleared AlarmEventType = "cleared"
+)
+
+// AlarmKind represents which sensor of a smokecoalarm an event is about
+type AlarmKind string
+
+const (
+	// SmokeAlarm is the AlarmKind for the smoke sensor
+	SmokeAlarm AlarmKind = "smoke"
+	// CoAlarm is the AlarmKind for the CO sensor
+	CoAlarm AlarmKind = "co"
+)
+
+// AlarmEvent represents a change in the smoke or CO alarm state of a smokecoalarm
+type AlarmEvent struct {
+	Type           AlarmEventType
+	Kind           AlarmKind
+	State          AlarmState
+	PreviousState  AlarmState
+	DeviceID       string
+	Name           string
+	StructureID    string
+	WhereID        string
+	WhereName      string
+	LastConnection time.Time
+	RaisedAt       time.Time
+	Time           time.Time
+}
+
+/*
+AlarmWatcher turns devices payloads into alarm events, ignoring payloads that repeat a known state
+and states it does not know. An alarm whose device is no longer in the payload is cleared with an
+empty State.
+*/
+type AlarmWatcher struct {
+	alarms map[string]*alarmStatus
+}
+
+// alarmStatus is the last known state of one sensor and the device it belongs to
+type alarmStatus struct {
+	state    AlarmState
+	raisedAt time.Time
+	kind     AlarmKind
+	device   *SmokeCoAlarm
+}
+
+/*
+NewAlarmWatcher creates a new AlarmWatcher
+
+	watcher := nest.NewAlarmWatcher()
+	events := watcher.Update(devices, time.Now())
+*/
+func NewAlarmWatcher() *AlarmWatcher {
+	return &AlarmWatcher{alarms: make(map[string]*alarmStatus)}
+}
+
+/*
+AlarmsStream emits smoke and CO alarm events from the Nest devices REST streaming API
+
+	client.AlarmsStream(func(event *nest.AlarmEvent, err error) {
+		fmt.Println(event.Type, event.Kind, event.WhereName)
+	})
+*/
+func (c *Client) AlarmsStream(callback func(event *AlarmEvent, err error)) {
+	watcher := NewAlarmWatcher()
+	c.DevicesStream(func(devices *Devices, err error) {
+		if err != nil {
+			callback(nil, err)
+			return
+		}
+		for _, event := range watcher.Update(devices, time.Now()) {
+			callback(event, nil)
+		}
+	})
+}
+
+// Update compares the smokecoalarms in devices with their last known states and returns the resulting events
+func (w *AlarmWatcher) Update(devices *Devices, now time.Time) []*AlarmEvent {
+	events := []*AlarmEvent{}
+	if devices == nil {
+		return events
+	}
+	ids := make([]string, 0, len(devices.SmokeCoAlarms))
+	for id := range devices.SmokeCoAlarms {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		alarm := devices.SmokeCoAlarms[id]
+		if alarm == nil {
+			continue
+		}
+		for _, kind := range []AlarmKind{SmokeAlarm, CoAlarm} {
+			state := alarm.SmokeAlarmState
+			if kind == CoAlarm {
+				state = alarm.CoAlarmState
+			}
+			key := id + "/" + string(kind)
+			seen[key] = true
+			event := w.update(key, kind, alarm, state, now)
+			if event != nil {
+				events = append(events, event)
+			}
+		}
+	}
+	return append(events, w.removed(seen, now)...)
+}
+
+// update records the state of one sensor and returns an event when it changed in a way worth reporting
+func (w *AlarmWatcher) update(key string, kind AlarmKind, alarm *SmokeCoAlarm, state AlarmState, now time.Time) *AlarmEvent {
+	status, ok := w.alarms[key]
+	if !ok {
+		status = &alarmStatus{state: AlarmOK, kind: kind}
+		w.alarms[key] = status
+	}
+	status.device = alarm
+	if !state.Valid() {
+		return nil
+	}
+	previous := status.state
+	status.state = state
+	event := newAlarmEvent(status, previous, now)
+	switch {
+	case previous.Level() == 0 && state.Level() > 0:
+		status.raisedAt = now
+		event.Type = AlarmRaised
+	case previous.Level() > 0 && state.Level() > previous.Level():
+		event.Type = AlarmEscalated
+	case state.Level() > 0 && state.Level() < previous.Level():
+		event.Type = AlarmDeescalated
+	case previous.Level() > 0 && state.Level() == 0:
+		event.Type = AlarmCleared
+	default:
+		return nil
+	}
+	event.RaisedAt = status.raisedAt
+	return event
+}
+
+// removed forgets the sensors of devices no longer in the payload, clearing those that were raised
+func (w *AlarmWatcher) removed(seen map[string]bool, now time.Time) []*AlarmEvent {
+	keys := []string{}
+	for key := range w.alarms {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	events := []*AlarmEvent{}
+	for _, key := range keys {
+		status := w.alarms[key]
+		delete(w.alarms, key)
+		if status.state.Level() == 0 {
+			continue
+		}
+		previous := status.state
+		status.state = ""
+		event := newAlarmEvent(status, previous, now)
+		event.Type = AlarmCleared
+		event.RaisedAt = status.raisedAt
+		events = append(events, event)
+	}
+	return events
+}
+
+// newAlarmEvent creates an event for the sensor's current state, describing its device
+func newAlarmEvent(status *alarmStatus, previous AlarmState, now time.Time) *AlarmEvent {
+	return &AlarmEvent{
+		Kind:           status.kind,
+		State:          status.state,
+		PreviousState:  previous,
+		DeviceID:       status.device.DeviceID,
+		Name:           status.device.Name,
+		StructureID:    status.device.StructureID,
+		WhereID:        status.device.WhereID,
+		WhereName:      status.device.WhereName,
+		LastConnection: status.device.LastConnection,
+		Time:           now,
+	}
+}
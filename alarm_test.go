@@ -0,0 +1,128 @@
+package nest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestAlarmWatcher(t *testing.T) {
+	Convey("Given an alarm watcher", t, func() {
+		watcher := NewAlarmWatcher()
+		start := time.Date(2014, 9, 1, 12, 0, 0, 0, time.UTC)
+		alarm := &SmokeCoAlarm{
+			DeviceID:        "a1234",
+			StructureID:     "s1234",
+			WhereName:       "Hallway",
+			SmokeAlarmState: AlarmOK,
+			CoAlarmState:    AlarmOK,
+		}
+		devices := &Devices{SmokeCoAlarms: map[string]*SmokeCoAlarm{"a1234": alarm}}
+
+		Convey("When all is well there should be no events", func() {
+			So(watcher.Update(devices, start), ShouldBeEmpty)
+		})
+
+		Convey("When an alarm goes through its lifecycle", func() {
+			watcher.Update(devices, start)
+			alarm.CoAlarmState = AlarmWarning
+			events := watcher.Update(devices, start.Add(time.Minute))
+			So(len(events), ShouldEqual, 1)
+			So(events[0].Type, ShouldEqual, AlarmRaised)
+			So(events[0].Kind, ShouldEqual, CoAlarm)
+			So(events[0].StructureID, ShouldEqual, "s1234")
+			So(events[0].WhereName, ShouldEqual, "Hallway")
+
+			Convey("Repeated payloads should not emit events", func() {
+				So(watcher.Update(devices, start.Add(2*time.Minute)), ShouldBeEmpty)
+			})
+
+			Convey("Going to emergency should escalate", func() {
+				alarm.CoAlarmState = AlarmEmergency
+				events := watcher.Update(devices, start.Add(2*time.Minute))
+				So(len(events), ShouldEqual, 1)
+				So(events[0].Type, ShouldEqual, AlarmEscalated)
+				So(events[0].PreviousState, ShouldEqual, AlarmWarning)
+				So(events[0].RaisedAt, ShouldEqual, start.Add(time.Minute))
+			})
+
+			Convey("Going from emergency back to warning should deescalate", func() {
+				alarm.CoAlarmState = AlarmEmergency
+				watcher.Update(devices, start.Add(2*time.Minute))
+				alarm.CoAlarmState = AlarmWarning
+				events := watcher.Update(devices, start.Add(3*time.Minute))
+				So(len(events), ShouldEqual, 1)
+				So(events[0].Type, ShouldEqual, AlarmDeescalated)
+				So(events[0].PreviousState, ShouldEqual, AlarmEmergency)
+				So(events[0].RaisedAt, ShouldEqual, start.Add(time.Minute))
+			})
+
+			Convey("An unknown or missing state should change nothing", func() {
+				alarm.CoAlarmState = ""
+				So(watcher.Update(devices, start.Add(2*time.Minute)), ShouldBeEmpty)
+				alarm.CoAlarmState = "sounding"
+				So(watcher.Update(devices, start.Add(3*time.Minute)), ShouldBeEmpty)
+				alarm.CoAlarmState = AlarmWarning
+				So(watcher.Update(devices, start.Add(4*time.Minute)), ShouldBeEmpty)
+			})
+
+			Convey("A raised alarm whose device goes away should clear", func() {
+				events := watcher.Update(&Devices{}, start.Add(2*time.Minute))
+				So(len(events), ShouldEqual, 1)
+				So(events[0].Type, ShouldEqual, AlarmCleared)
+				So(events[0].Kind, ShouldEqual, CoAlarm)
+				So(events[0].State, ShouldEqual, AlarmState(""))
+				So(events[0].PreviousState, ShouldEqual, AlarmWarning)
+				So(events[0].WhereName, ShouldEqual, "Hallway")
+				So(watcher.Update(&Devices{}, start.Add(3*time.Minute)), ShouldBeEmpty)
+
+				Convey("and be raised again if it comes back", func() {
+					events := watcher.Update(devices, start.Add(4*time.Minute))
+					So(len(events), ShouldEqual, 1)
+					So(events[0].Type, ShouldEqual, AlarmRaised)
+				})
+			})
+
+			Convey("Going back to ok should clear", func() {
+				alarm.CoAlarmState = AlarmOK
+				events := watcher.Update(devices, start.Add(3*time.Minute))
+				So(len(events), ShouldEqual, 1)
+				So(events[0].Type, ShouldEqual, AlarmCleared)
+				So(events[0].Time, ShouldEqual, start.Add(3*time.Minute))
+			})
+		})
+
+		Convey("An alarm already sounding when first seen should be raised", func() {
+			alarm.SmokeAlarmState = AlarmEmergency
+			events := watcher.Update(devices, start)
+			So(len(events), ShouldEqual, 1)
+			So(events[0].Type, ShouldEqual, AlarmRaised)
+			So(events[0].Kind, ShouldEqual, SmokeAlarm)
+		})
+	})
+}
+
+func TestAlarmsStream(t *testing.T) {
+	Convey("When requesting an alarms stream we should get one event for the repeated payloads", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.APIURL = ts.URL
+		eventsChan := make(chan *AlarmEvent, 10)
+		go func() {
+			client.AlarmsStream(func(event *AlarmEvent, err error) {
+				if err == nil {
+					eventsChan <- event
+				}
+			})
+		}()
+		event := <-eventsChan
+		So(event.Type, ShouldEqual, AlarmRaised)
+		So(event.DeviceID, ShouldEqual, "a3455")
+		So(event.State, ShouldEqual, AlarmWarning)
+		select {
+		case event = <-eventsChan:
+			So(event, ShouldBeNil)
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package nest
+
+import (
+	"encoding/json"
+)
+
+/*
+SetStreaming turns the camera on or off
+https://developer.nest.com/documentation/api#is_streaming
+
+	c.SetStreaming(false)
+*/
+func (c *Camera) SetStreaming(streaming bool) *APIError {
+	request := make(map[string]bool)
+	request["is_streaming"] = streaming
+	body, _ := json.Marshal(request)
+	return c.setCamera(body)
+}
+
+// setCamera sends the request to the Nest REST API and applies the result to the camera
+func (c *Camera) setCamera(body []byte) *APIError {
+	response, apiErr := c.Client.put("/devices/cameras/"+c.DeviceID, body)
+	if apiErr != nil {
+		return apiErr
+	}
+	json.Unmarshal(response, c)
+	if c.Client.ConfirmTimeout > 0 {
+		confirmed, apiErr := c.Client.confirm("/devices.json", []string{"cameras", c.DeviceID}, body)
+		if apiErr != nil {
+			return apiErr
+		}
+		json.Unmarshal(confirmed, c)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package nest
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestCamera(t *testing.T) {
+	Convey("Given a JSON object with a camera", t, func() {
+		combined := &Combined{}
+		err := json.Unmarshal(combinedJSON(), combined)
+		So(err, ShouldBeNil)
+		camera := combined.Devices.Cameras["awJo6rHcRE3Sr2M1TAvrBGYSb2ZgqtLXJFZI5HyOdvKIj"]
+
+		Convey("We should get its last event", func() {
+			So(camera.LastEvent.HasMotion, ShouldBeTrue)
+			So(camera.LastEvent.HasPerson, ShouldBeFalse)
+			So(camera.LastEvent.StartTime, ShouldEqual, time.Date(2016, 12, 29, 0, 0, 0, 0, time.UTC))
+			So(camera.LastEvent.ActivityZoneIDs, ShouldResemble, []string{"244083"})
+			So(camera.ActivityZones[0].Name, ShouldEqual, "Walkway")
+		})
+
+		Convey("The structure should list the camera", func() {
+			structure := combined.Structures["VqFabWH21nwVyd4RWgJgNb292wa7hG_dUwo2i2SG7j3-BOLY0BA4sw"]
+			So(structure.Cameras, ShouldResemble, []string{camera.DeviceID})
+		})
+	})
+}
+
+func TestSetStreaming(t *testing.T) {
+	Convey("When turning a camera off", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.APIURL = ts.URL
+		devices, _ := client.Devices()
+		client.RedirectURL = ts.URL
+		camera := devices.Cameras["c1234"]
+		So(camera.IsStreaming, ShouldBeTrue)
+		err := camera.SetStreaming(false)
+		So(err, ShouldBeNil)
+		So(camera.IsStreaming, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,134 @@
+package nest
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// CameraActivityType represents whether a camera event began or ended
+type CameraActivityType string
+
+const (
+	// CameraEventStarted is emitted when a camera reports a new last_event
+	CameraEventStarted CameraActivityType = "started"
+	// CameraEventEnded is emitted when a camera's last_event gets its end time
+	CameraEventEnded CameraActivityType = "ended"
+)
+
+// CameraActivity represents the start or end of a sound, motion or person event on a camera
+type CameraActivity struct {
+	Type             CameraActivityType
+	DeviceID         string
+	Name             string
+	StructureID      string
+	WhereName        string
+	Event            *CameraEvent
+	Zones            []*ActivityZone
+	ImageURL         string
+	AnimatedImageURL string
+	SnapshotURL      string
+	Time             time.Time
+}
+
+// CameraWatcher tracks each camera's last_event across devices payloads so every event is reported once
+type CameraWatcher struct {
+	cameras map[string]*cameraStatus
+}
+
+// cameraStatus is the last known event of one camera
+type cameraStatus struct {
+	start time.Time
+	ended bool
+}
+
+/*
+NewCameraWatcher creates a new CameraWatcher. The first payload seen for a camera only
+records its last_event, so restarting a watcher does not report old events again.
+
+	watcher := nest.NewCameraWatcher()
+	activities := watcher.Update(devices, time.Now())
+*/
+func NewCameraWatcher() *CameraWatcher {
+	return &CameraWatcher{cameras: make(map[string]*cameraStatus)}
+}
+
+/*
+CameraEventsStream emits camera activity from the Nest devices REST streaming API
+
+	client.CameraEventsStream(func(activity *nest.CameraActivity, err error) {
+		fmt.Println(activity.Type, activity.Name, activity.AnimatedImageURL)
+	})
+*/
+func (c *Client) CameraEventsStream(callback func(activity *CameraActivity, err error)) {
+	watcher := NewCameraWatcher()
+	c.DevicesStream(func(devices *Devices, err error) {
+		if err != nil {
+			callback(nil, err)
+			return
+		}
+		for _, activity := range watcher.Update(devices, time.Now()) {
+			callback(activity, nil)
+		}
+	})
+}
+
+// Update compares each camera's last_event with the one last seen and returns the resulting activity
+func (w *CameraWatcher) Update(devices *Devices, now time.Time) []*CameraActivity {
+	activities := []*CameraActivity{}
+	if devices == nil {
+		return activities
+	}
+	ids := make([]string, 0, len(devices.Cameras))
+	for id := range devices.Cameras {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		camera := devices.Cameras[id]
+		event := camera.LastEvent
+		if event == nil || event.StartTime.IsZero() {
+			continue
+		}
+		ended := !event.EndTime.IsZero() && !event.EndTime.Before(event.StartTime)
+		status, ok := w.cameras[id]
+		if !ok {
+			w.cameras[id] = &cameraStatus{start: event.StartTime, ended: ended}
+			continue
+		}
+		if event.StartTime.After(status.start) {
+			status.start = event.StartTime
+			status.ended = false
+			activities = append(activities, newCameraActivity(CameraEventStarted, camera, now))
+		}
+		if event.StartTime.Equal(status.start) && ended && !status.ended {
+			status.ended = true
+			activities = append(activities, newCameraActivity(CameraEventEnded, camera, now))
+		}
+	}
+	return activities
+}
+
+// newCameraActivity creates the activity for a camera's last_event, resolving its activity zones
+func newCameraActivity(activityType CameraActivityType, camera *Camera, now time.Time) *CameraActivity {
+	activity := &CameraActivity{
+		Type:             activityType,
+		DeviceID:         camera.DeviceID,
+		Name:             camera.Name,
+		StructureID:      camera.StructureID,
+		WhereName:        camera.WhereName,
+		Event:            camera.LastEvent,
+		ImageURL:         camera.LastEvent.ImageURL,
+		AnimatedImageURL: camera.LastEvent.AnimatedImageURL,
+		SnapshotURL:      camera.SnapshotURL,
+		Time:             now,
+	}
+	for _, zoneID := range camera.LastEvent.ActivityZoneIDs {
+		for _, zone := range camera.ActivityZones {
+			if strconv.Itoa(zone.ID) == zoneID {
+				activity.Zones = append(activity.Zones, zone)
+			}
+		}
+	}
+	return activity
+}
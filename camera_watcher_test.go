@@ -0,0 +1,81 @@
+package nest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestCameraWatcher(t *testing.T) {
+	Convey("Given a camera watcher fed by a fake stream", t, func() {
+		watcher := NewCameraWatcher()
+		now := time.Date(2016, 12, 29, 12, 0, 0, 0, time.UTC)
+		first := now.Add(-time.Hour)
+		camera := &Camera{
+			DeviceID:      "c1234",
+			Name:          "Front Door",
+			SnapshotURL:   "https://example.com/snapshot",
+			ActivityZones: []*ActivityZone{{Name: "Walkway", ID: 244083}, {Name: "Driveway", ID: 244093}},
+			LastEvent:     &CameraEvent{StartTime: first, EndTime: first.Add(30 * time.Second)},
+		}
+		devices := &Devices{Cameras: map[string]*Camera{"c1234": camera}}
+		So(watcher.Update(devices, now), ShouldBeEmpty)
+
+		Convey("The event seen at startup should not be reported again", func() {
+			So(watcher.Update(devices, now), ShouldBeEmpty)
+		})
+
+		Convey("A new event should be reported when it begins and when it ends", func() {
+			camera.LastEvent = &CameraEvent{
+				HasPerson:        true,
+				StartTime:        now,
+				EndTime:          first.Add(30 * time.Second),
+				AnimatedImageURL: "https://example.com/animated",
+				ActivityZoneIDs:  []string{"244093"},
+			}
+			activities := watcher.Update(devices, now)
+			So(len(activities), ShouldEqual, 1)
+			So(activities[0].Type, ShouldEqual, CameraEventStarted)
+			So(activities[0].Event.HasPerson, ShouldBeTrue)
+			So(activities[0].Zones[0].Name, ShouldEqual, "Driveway")
+			So(activities[0].AnimatedImageURL, ShouldEqual, "https://example.com/animated")
+			So(activities[0].SnapshotURL, ShouldEqual, "https://example.com/snapshot")
+			So(watcher.Update(devices, now), ShouldBeEmpty)
+
+			camera.LastEvent.EndTime = now.Add(time.Minute)
+			activities = watcher.Update(devices, now.Add(time.Minute))
+			So(len(activities), ShouldEqual, 1)
+			So(activities[0].Type, ShouldEqual, CameraEventEnded)
+			So(watcher.Update(devices, now.Add(2*time.Minute)), ShouldBeEmpty)
+		})
+
+		Convey("An event that began and ended between payloads should be reported twice", func() {
+			camera.LastEvent = &CameraEvent{StartTime: now, EndTime: now.Add(10 * time.Second)}
+			activities := watcher.Update(devices, now.Add(time.Minute))
+			So(len(activities), ShouldEqual, 2)
+			So(activities[0].Type, ShouldEqual, CameraEventStarted)
+			So(activities[1].Type, ShouldEqual, CameraEventEnded)
+		})
+	})
+}
+
+func TestCameraEventsStream(t *testing.T) {
+	Convey("When requesting a camera events stream the event seen at startup should not be reported", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.APIURL = ts.URL
+		activities := make(chan *CameraActivity, 10)
+		go func() {
+			client.CameraEventsStream(func(activity *CameraActivity, err error) {
+				if err == nil {
+					activities <- activity
+				}
+			})
+		}()
+		select {
+		case activity := <-activities:
+			So(activity, ShouldBeNil)
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,46 @@
+/*
+Command nest-exporter serves the state of Nest devices and structures as Prometheus metrics.
+
+	nest-exporter [-listen :9264] [-token <token>] [-api-url <url>]
+
+The token is read from the -token flag or the NEST_TOKEN environment variable. Metrics are
+served on /metrics and kept current from the Nest streaming API.
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/jsgoecke/nest"
+	"github.com/jsgoecke/nest/exporter"
+)
+
+func main() {
+	listen := flag.String("listen", ":9264", "address to serve metrics on")
+	token := flag.String("token", os.Getenv("NEST_TOKEN"), "access token, defaults to NEST_TOKEN")
+	apiURL := flag.String("api-url", "", "URL of the Nest API")
+	retryDelay := flag.Duration("retry-delay", exporter.DefaultRetryDelay, "how long to wait before reconnecting a failed stream")
+	flag.Parse()
+	if *token == "" {
+		log.Fatal("nest-exporter: no token, use -token or set NEST_TOKEN")
+	}
+
+	client := nest.New("", "", "", "")
+	client.Token = *token
+	if *apiURL != "" {
+		client.APIURL = *apiURL
+	}
+	e := exporter.New(client)
+	e.RetryDelay = *retryDelay
+	apiErr := e.Start()
+	if apiErr != nil {
+		log.Fatalf("nest-exporter: %s %s", apiErr.Error, apiErr.Description)
+	}
+
+	http.Handle("/metrics", e)
+	log.Printf("nest-exporter: serving metrics on %s/metrics", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
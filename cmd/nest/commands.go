@@ -0,0 +1,500 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsgoecke/nest"
+)
+
+// auth exchanges an authorization code for a token and saves it
+func (c *cli) auth(flags *flag.FlagSet, args []string) error {
+	clientID := flags.String("client-id", "", "client ID of the Nest product")
+	clientSecret := flags.String("client-secret", "", "client secret of the Nest product")
+	code := flags.String("code", "", "authorization code from https://home.nest.com/login/oauth2")
+	accessTokenURL := flags.String("access-token-url", nest.AccessTokenURL, "URL to exchange the code at")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *clientID == "" || *clientSecret == "" || *code == "" {
+		return &usageError{"auth needs -client-id, -client-secret and -code"}
+	}
+	client := nest.New(*clientID, "STATE", *clientSecret, *code)
+	client.AccessTokenURL = *accessTokenURL
+	apiErr := client.Authorize()
+	if apiErr != nil {
+		return apiError(apiErr)
+	}
+	config, err := c.loadConfig()
+	if err != nil {
+		return err
+	}
+	config.Token = client.Token
+	if c.apiURL != "" {
+		config.APIURL = c.apiURL
+	}
+	err = c.saveConfig(config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "Token saved to "+c.configPath)
+	return nil
+}
+
+// devices lists the devices as a table or JSON
+func (c *cli) devices(flags *flag.FlagSet, args []string) error {
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	err := parseArgs(flags, args, 0)
+	if err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	devices, apiErr := client.Devices()
+	if apiErr != nil {
+		return apiError(apiErr)
+	}
+	if *asJSON {
+		return printJSON(c.stdout, devices)
+	}
+	printDevices(c.stdout, devices)
+	return nil
+}
+
+// structures lists the structures as a table or JSON
+func (c *cli) structures(flags *flag.FlagSet, args []string) error {
+	asJSON := flags.Bool("json", false, "print JSON instead of a table")
+	err := parseArgs(flags, args, 0)
+	if err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	structures, apiErr := client.Structures()
+	if apiErr != nil {
+		return apiError(apiErr)
+	}
+	if *asJSON {
+		return printJSON(c.stdout, structures)
+	}
+	printStructures(c.stdout, structures)
+	return nil
+}
+
+// get prints a thermostat as JSON, or one of its fields
+func (c *cli) get(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return &usageError{"get needs a thermostat and optionally a field"}
+	}
+	thermostat, err := c.thermostat(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 1 {
+		return printJSON(c.stdout, thermostat)
+	}
+	data, _ := json.Marshal(thermostat)
+	fields := make(map[string]interface{})
+	json.Unmarshal(data, &fields)
+	value, ok := fields[flags.Arg(1)]
+	if !ok {
+		return errors.New("no field " + flags.Arg(1) + " is set on the thermostat")
+	}
+	if text, isString := value.(string); isString {
+		fmt.Fprintln(c.stdout, text)
+		return nil
+	}
+	data, _ = json.Marshal(value)
+	fmt.Fprintln(c.stdout, string(data))
+	return nil
+}
+
+// set writes thermostat fields given as field=value
+func (c *cli) set(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		return &usageError{"set needs a thermostat and at least one field=value"}
+	}
+	values := make(map[string]string)
+	for _, arg := range flags.Args()[1:] {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return &usageError{"expected field=value, got " + arg}
+		}
+		values[parts[0]] = parts[1]
+	}
+	thermostat, err := c.thermostat(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	err = setThermostat(thermostat, values)
+	if err != nil {
+		return err
+	}
+	thermostat, err = c.thermostat(thermostat.DeviceID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, thermostatSummary(thermostat))
+	return nil
+}
+
+// away sets the away mode of a structure
+func (c *cli) away(flags *flag.FlagSet, args []string) error {
+	err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	structure, err := c.structure(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	apiErr := structure.SetAway(nest.AwayMode(flags.Arg(1)))
+	if apiErr != nil {
+		return apiError(apiErr)
+	}
+	fmt.Fprintln(c.stdout, structure.Name+" is "+structure.Away.String())
+	return nil
+}
+
+// eta sets or cancels the ETA of a trip to a structure
+func (c *cli) eta(flags *flag.FlagSet, args []string) error {
+	window := flags.Duration("window", nest.DefaultTripWindow, "width of the arrival window")
+	err := parseArgs(flags, args, 3)
+	if err != nil {
+		return err
+	}
+	structure, err := c.structure(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	tripID := flags.Arg(1)
+	if flags.Arg(2) == "cancel" {
+		apiErr := structure.CancelETA(tripID)
+		if apiErr != nil {
+			return apiError(apiErr)
+		}
+		fmt.Fprintln(c.stdout, "Cancelled trip "+tripID)
+		return nil
+	}
+	arrivalIn, err := time.ParseDuration(flags.Arg(2))
+	if err != nil {
+		return &usageError{"arrival must be a duration such as 25m, or cancel"}
+	}
+	begin := time.Now().Add(arrivalIn)
+	apiErr := structure.SetETA(tripID, begin, begin.Add(*window))
+	if apiErr != nil {
+		return apiError(apiErr)
+	}
+	fmt.Fprintln(c.stdout, "Trip "+tripID+" arriving between "+begin.Format("15:04")+" and "+begin.Add(*window).Format("15:04"))
+	return nil
+}
+
+// watch prints updates from the devices or structures stream
+func (c *cli) watch(flags *flag.FlagSet, args []string) error {
+	watchStructures := flags.Bool("structures", false, "watch structures instead of devices")
+	asJSON := flags.Bool("json", false, "print each update as a line of JSON")
+	count := flags.Int("count", 0, "stop after this many updates, 0 for no limit")
+	err := parseArgs(flags, args, 0)
+	if err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	updates := make(chan interface{})
+	errs := make(chan error)
+	if *watchStructures {
+		go client.StructuresStream(func(structures map[string]*nest.Structure, err error) {
+			if err != nil {
+				errs <- err
+				return
+			}
+			updates <- structures
+		})
+	} else {
+		go client.DevicesStream(func(devices *nest.Devices, err error) {
+			if err != nil {
+				errs <- err
+				return
+			}
+			updates <- devices
+		})
+	}
+	for seen := 0; *count == 0 || seen < *count; seen++ {
+		select {
+		case err := <-errs:
+			return err
+		case update := <-updates:
+			if *asJSON {
+				data, _ := json.Marshal(update)
+				fmt.Fprintln(c.stdout, string(data))
+				continue
+			}
+			switch update := update.(type) {
+			case *nest.Devices:
+				printDevices(c.stdout, update)
+			case map[string]*nest.Structure:
+				printStructures(c.stdout, update)
+			}
+			fmt.Fprintln(c.stdout)
+		}
+	}
+	return nil
+}
+
+// thermostat fetches the devices and finds a thermostat in them
+func (c *cli) thermostat(key string) (*nest.Thermostat, error) {
+	client, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	devices, apiErr := client.Devices()
+	if apiErr != nil {
+		return nil, apiError(apiErr)
+	}
+	return findThermostat(devices, key)
+}
+
+// structure fetches the structures and finds one in them
+func (c *cli) structure(key string) (*nest.Structure, error) {
+	client, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	structures, apiErr := client.Structures()
+	if apiErr != nil {
+		return nil, apiError(apiErr)
+	}
+	return findStructure(structures, key)
+}
+
+// parseArgs parses the flags and checks the number of arguments left
+func parseArgs(flags *flag.FlagSet, args []string, count int) error {
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != count {
+		return &usageError{"expected " + strconv.Itoa(count) + " arguments, got " + strconv.Itoa(flags.NArg())}
+	}
+	return nil
+}
+
+// settableFields are the fields set accepts
+var settableFields = []string{"target", "target_high", "target_low", "hvac_mode", "label", "temperature_scale", "fan_timer_active", "fan_timer_duration"}
+
+// setThermostat applies the values to the thermostat, changing the mode before any temperatures
+func setThermostat(t *nest.Thermostat, values map[string]string) error {
+	for field := range values {
+		known := false
+		for _, settable := range settableFields {
+			known = known || field == settable
+		}
+		if !known {
+			return &usageError{"cannot set " + field + ", use one of " + strings.Join(settableFields, ", ")}
+		}
+	}
+	order := []string{"temperature_scale", "label", "fan_timer_duration", "fan_timer_active", "hvac_mode"}
+	for _, field := range order {
+		value, ok := values[field]
+		if !ok {
+			continue
+		}
+		delete(values, field)
+		var apiErr *nest.APIError
+		switch field {
+		case "temperature_scale":
+			apiErr = t.SetTemperatureScale(nest.Scale(strings.ToUpper(value)))
+		case "label":
+			apiErr = t.SetLabel(value)
+		case "fan_timer_duration":
+			minutes, err := strconv.Atoi(value)
+			if err != nil {
+				return &usageError{"fan_timer_duration must be a number of minutes"}
+			}
+			apiErr = t.SetFanTimerDuration(minutes)
+		case "fan_timer_active":
+			active, err := strconv.ParseBool(value)
+			if err != nil {
+				return &usageError{"fan_timer_active must be true or false"}
+			}
+			apiErr = t.SetFanTimerActive(active)
+		case "hvac_mode":
+			apiErr = t.SetHvacMode(nest.HvacMode(value))
+		}
+		if apiErr != nil {
+			return apiError(apiErr)
+		}
+	}
+	if value, ok := values["target"]; ok {
+		delete(values, "target")
+		temp, err := parseTemperature(value, t)
+		if err != nil {
+			return err
+		}
+		apiErr := t.SetTarget(temp)
+		if apiErr != nil {
+			return apiError(apiErr)
+		}
+	}
+	high, hasHigh := values["target_high"]
+	low, hasLow := values["target_low"]
+	if hasHigh || hasLow {
+		delete(values, "target_high")
+		delete(values, "target_low")
+		highTemp, lowTemp := t.TargetHigh(), t.TargetLow()
+		var err error
+		if hasHigh {
+			highTemp, err = parseTemperature(high, t)
+		}
+		if err == nil && hasLow {
+			lowTemp, err = parseTemperature(low, t)
+		}
+		if err != nil {
+			return err
+		}
+		apiErr := t.SetTargetHighLow(highTemp, lowTemp)
+		if apiErr != nil {
+			return apiError(apiErr)
+		}
+	}
+	return nil
+}
+
+// parseTemperature reads a temperature such as 72, 72F or 21.5C, using the thermostat's scale when none is given
+func parseTemperature(value string, t *nest.Thermostat) (nest.Temperature, error) {
+	scale := t.TemperatureScale
+	upper := strings.ToUpper(strings.TrimSuffix(value, "°"))
+	if strings.HasSuffix(upper, "F") || strings.HasSuffix(upper, "C") {
+		scale = nest.Scale(upper[len(upper)-1:])
+		upper = strings.TrimSuffix(upper[:len(upper)-1], "°")
+	}
+	if scale != nest.Celsius {
+		scale = nest.Fahrenheit
+	}
+	number, err := strconv.ParseFloat(upper, 64)
+	if err != nil {
+		return nest.Temperature{}, &usageError{"invalid temperature " + value}
+	}
+	return nest.Temperature{Value: number, Scale: scale}, nil
+}
+
+// printJSON prints value as indented JSON
+func printJSON(w io.Writer, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, string(data))
+	return nil
+}
+
+// printDevices prints a table of the devices, ordered by type and ID
+func printDevices(w io.Writer, devices *nest.Devices) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "TYPE\tID\tNAME\tONLINE\tSTATUS")
+	for _, id := range sortedKeys(devices.Thermostats) {
+		t := devices.Thermostats[id]
+		fmt.Fprintf(table, "thermostat\t%s\t%s\t%s\t%s\n", id, t.NameLong, yesNo(t.IsOnline), thermostatStatus(t))
+	}
+	for _, id := range sortedKeys(devices.SmokeCoAlarms) {
+		a := devices.SmokeCoAlarms[id]
+		status := fmt.Sprintf("smoke %s, co %s, battery %s", a.SmokeAlarmState, a.CoAlarmState, a.BatteryHealth)
+		fmt.Fprintf(table, "smoke_co_alarm\t%s\t%s\t%s\t%s\n", id, a.NameLong, yesNo(a.IsOnline), status)
+	}
+	for _, id := range sortedKeys(devices.Cameras) {
+		camera := devices.Cameras[id]
+		status := "not streaming"
+		if camera.IsStreaming {
+			status = "streaming"
+		}
+		fmt.Fprintf(table, "camera\t%s\t%s\t%s\t%s\n", id, camera.NameLong, yesNo(camera.IsOnline), status)
+	}
+	table.Flush()
+}
+
+// printStructures prints a table of the structures, ordered by ID
+func printStructures(w io.Writer, structures map[string]*nest.Structure) {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tNAME\tAWAY\tTHERMOSTATS\tSMOKE_CO_ALARMS\tCAMERAS")
+	for _, id := range sortedKeys(structures) {
+		s := structures[id]
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%d\t%d\n", id, s.Name, s.Away, len(s.Thermostats), len(s.SmokeCoAlarms), len(s.Cameras))
+	}
+	table.Flush()
+}
+
+// thermostatStatus describes what a thermostat is doing
+func thermostatStatus(t *nest.Thermostat) string {
+	status := t.FormatTemperature(t.Ambient()) + ", " + t.HvacMode.String()
+	switch t.HvacMode {
+	case nest.Heat, nest.Cool:
+		status += " to " + t.FormatTemperature(t.Target())
+	case nest.HeatCool:
+		status += " " + t.FormatTemperature(t.TargetLow()) + "-" + t.FormatTemperature(t.TargetHigh())
+	}
+	if t.HvacState != "" {
+		status += ", " + t.HvacState.String()
+	}
+	return status
+}
+
+// thermostatSummary names a thermostat along with its status
+func thermostatSummary(t *nest.Thermostat) string {
+	return t.NameLong + ": " + thermostatStatus(t)
+}
+
+// yesNo formats a bool for a table
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// sortedKeys returns the keys of a map keyed by ID in order
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch m := m.(type) {
+	case map[string]*nest.Thermostat:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*nest.SmokeCoAlarm:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*nest.Camera:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*nest.Structure:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/jsgoecke/nest"
+	"github.com/nsf/termbox-go"
+)
+
+// dashboard is the state of the live full-screen view of every structure and its devices
+type dashboard struct {
+	structures map[string]*nest.Structure
+	devices    *nest.Devices
+	selected   string
+	status     string
+}
+
+// dashboardRow is one line of the dashboard, pointing at what it shows
+type dashboardRow struct {
+	id         string
+	text       string
+	color      termbox.Attribute
+	structure  *nest.Structure
+	thermostat *nest.Thermostat
+	selected   bool
+}
+
+// dashboardHelp lists the keys of the dashboard
+const dashboardHelp = "↑/↓ select  +/- adjust target  a toggle away  q quit"
+
+// dashboard shows the dashboard until the user quits
+func (c *cli) dashboard(flags *flag.FlagSet, args []string) error {
+	err := parseArgs(flags, args, 0)
+	if err != nil {
+		return err
+	}
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+	devicesUpdates := make(chan *nest.Devices)
+	structuresUpdates := make(chan map[string]*nest.Structure)
+	errs := make(chan error)
+	go client.DevicesStream(func(devices *nest.Devices, err error) {
+		if err != nil {
+			errs <- err
+			return
+		}
+		devicesUpdates <- devices
+	})
+	go client.StructuresStream(func(structures map[string]*nest.Structure, err error) {
+		if err != nil {
+			errs <- err
+			return
+		}
+		structuresUpdates <- structures
+	})
+
+	err = termbox.Init()
+	if err != nil {
+		return err
+	}
+	defer termbox.Close()
+	events := make(chan termbox.Event)
+	go func() {
+		for {
+			events <- termbox.PollEvent()
+		}
+	}()
+	d := &dashboard{status: "Connecting..."}
+	for {
+		d.draw()
+		select {
+		case devices := <-devicesUpdates:
+			d.devices = devices
+			d.status = ""
+		case structures := <-structuresUpdates:
+			d.structures = structures
+		case err := <-errs:
+			d.status = "Error: " + err.Error()
+		case event := <-events:
+			if event.Type == termbox.EventError {
+				return event.Err
+			}
+			if event.Type == termbox.EventKey && !d.handleKey(event.Key, event.Ch) {
+				return nil
+			}
+		}
+	}
+}
+
+// draw paints the dashboard on the terminal
+func (d *dashboard) draw() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	width, height := termbox.Size()
+	for y, row := range d.render(height) {
+		background := termbox.ColorDefault
+		if row.selected {
+			background = termbox.ColorBlue
+		}
+		x := 0
+		for _, r := range row.text {
+			if x >= width {
+				break
+			}
+			termbox.SetCell(x, y, r, row.color, background)
+			x++
+		}
+	}
+	termbox.Flush()
+}
+
+// render lays the dashboard out in at most height rows
+func (d *dashboard) render(height int) []*dashboardRow {
+	rows := []*dashboardRow{{text: "Nest  " + dashboardHelp, color: termbox.ColorDefault | termbox.AttrBold}}
+	selectable := d.rows()
+	if len(selectable) == 0 {
+		rows = append(rows, &dashboardRow{text: "No structures", color: termbox.ColorDefault})
+	}
+	selected := d.selectedIndex(selectable)
+	for i, row := range selectable {
+		row.text = "  " + row.text
+		if i == selected {
+			row.text = "> " + row.text[2:]
+			row.selected = true
+		}
+		rows = append(rows, row)
+	}
+	if height > 0 && len(rows) > height-1 {
+		rows = rows[:height-1]
+	}
+	return append(rows, &dashboardRow{text: d.status, color: termbox.ColorYellow})
+}
+
+// rows lists every structure followed by its devices, by name
+func (d *dashboard) rows() []*dashboardRow {
+	rows := []*dashboardRow{}
+	structures := make([]*nest.Structure, 0, len(d.structures))
+	for _, structure := range d.structures {
+		structures = append(structures, structure)
+	}
+	sort.Slice(structures, func(i, j int) bool {
+		return structures[i].Name < structures[j].Name
+	})
+	devices := d.devices
+	if devices == nil {
+		devices = &nest.Devices{}
+	}
+	for _, s := range structures {
+		color := termbox.ColorGreen
+		if s.SmokeAlarmState.Level() > 0 || s.CoAlarmState.Level() > 0 {
+			color = termbox.ColorRed
+		}
+		rows = append(rows, &dashboardRow{text: fmt.Sprintf("%s  [%s]", s.Name, s.Away), color: color | termbox.AttrBold, id: s.StructureID, structure: s})
+		thermostats := s.ThermostatsIn(devices)
+		sort.Slice(thermostats, func(i, j int) bool {
+			return thermostats[i].NameLong < thermostats[j].NameLong
+		})
+		for _, t := range thermostats {
+			color := termbox.ColorDefault
+			switch t.HvacState {
+			case nest.Heating:
+				color = termbox.ColorRed
+			case nest.Cooling:
+				color = termbox.ColorCyan
+			}
+			if !t.IsOnline {
+				color = termbox.ColorWhite
+			}
+			text := fmt.Sprintf("  %-28s %-7s %s", t.NameLong, onlineStatus(t.IsOnline), thermostatStatus(t))
+			rows = append(rows, &dashboardRow{id: t.DeviceID, text: text, color: color, structure: s, thermostat: t})
+		}
+		alarms := s.SmokeCoAlarmsIn(devices)
+		sort.Slice(alarms, func(i, j int) bool {
+			return alarms[i].NameLong < alarms[j].NameLong
+		})
+		for _, a := range alarms {
+			color := termbox.ColorDefault
+			if a.IsAlarming() {
+				color = termbox.ColorRed | termbox.AttrBold
+			} else if a.NeedsBattery() || !a.IsOnline {
+				color = termbox.ColorYellow
+			}
+			text := fmt.Sprintf("  %-28s %-7s smoke %s, co %s, battery %s", a.NameLong, onlineStatus(a.IsOnline), a.SmokeAlarmState, a.CoAlarmState, a.BatteryHealth)
+			rows = append(rows, &dashboardRow{id: a.DeviceID, text: text, color: color, structure: s})
+		}
+		cameras := s.CamerasIn(devices)
+		sort.Slice(cameras, func(i, j int) bool {
+			return cameras[i].NameLong < cameras[j].NameLong
+		})
+		for _, camera := range cameras {
+			color := termbox.ColorDefault
+			if !camera.IsOnline {
+				color = termbox.ColorYellow
+			}
+			streaming := "not streaming"
+			if camera.IsStreaming {
+				streaming = "streaming"
+			}
+			text := fmt.Sprintf("  %-28s %-7s %s", camera.NameLong, onlineStatus(camera.IsOnline), streaming)
+			rows = append(rows, &dashboardRow{id: camera.DeviceID, text: text, color: color, structure: s})
+		}
+	}
+	return rows
+}
+
+// selectedIndex finds the selected structure or device in rows, which are rebuilt on every update.
+// Nothing is selected at first, which means the first row, and -1 is returned once the selected one is gone.
+func (d *dashboard) selectedIndex(rows []*dashboardRow) int {
+	if d.selected == "" && len(rows) > 0 {
+		return 0
+	}
+	for i, row := range rows {
+		if row.id == d.selected {
+			return i
+		}
+	}
+	return -1
+}
+
+// selectedRow returns the row under the cursor, or nil
+func (d *dashboard) selectedRow() *dashboardRow {
+	rows := d.rows()
+	i := d.selectedIndex(rows)
+	if i < 0 {
+		return nil
+	}
+	return rows[i]
+}
+
+// handleKey acts on a key press, returning false when the dashboard should close
+func (d *dashboard) handleKey(key termbox.Key, ch rune) bool {
+	rows := d.rows()
+	selected := d.selectedIndex(rows)
+	switch {
+	case key == termbox.KeyCtrlC || key == termbox.KeyEsc || ch == 'q':
+		return false
+	case key == termbox.KeyArrowUp || ch == 'k':
+		if selected > 0 {
+			d.selected = rows[selected-1].id
+		} else if len(rows) > 0 {
+			d.selected = rows[0].id
+		}
+	case key == termbox.KeyArrowDown || ch == 'j':
+		if selected < len(rows)-1 {
+			d.selected = rows[selected+1].id
+		}
+	case ch == '+' || ch == '=':
+		d.adjustTarget(1)
+	case ch == '-':
+		d.adjustTarget(-1)
+	case ch == 'a':
+		d.toggleAway()
+	}
+	return true
+}
+
+// adjustTarget moves the selected thermostat's target, or its whole range in heat-cool, one step up or down
+func (d *dashboard) adjustTarget(direction float64) {
+	row := d.selectedRow()
+	if row == nil || row.thermostat == nil {
+		d.status = "Select a thermostat to adjust its target"
+		return
+	}
+	t := row.thermostat
+	step := 1.0
+	if t.TemperatureScale == nest.Celsius {
+		step = 0.5
+	}
+	shift := func(temp nest.Temperature) nest.Temperature {
+		temp.Value += direction * step
+		return temp
+	}
+	var apiErr *nest.APIError
+	switch t.HvacMode {
+	case nest.Heat, nest.Cool:
+		target := shift(t.Target())
+		apiErr = t.SetTarget(target)
+		d.status = t.NameLong + " target set to " + t.FormatTemperature(target)
+	case nest.HeatCool:
+		low, high := shift(t.TargetLow()), shift(t.TargetHigh())
+		apiErr = t.SetTargetHighLow(high, low)
+		d.status = t.NameLong + " range set to " + t.FormatTemperature(low) + "-" + t.FormatTemperature(high)
+	default:
+		d.status = "Cannot adjust the target of " + t.NameLong + " in " + t.HvacMode.String() + " mode"
+		return
+	}
+	if apiErr != nil {
+		d.status = "Error: " + apiError(apiErr).Error()
+	}
+}
+
+// toggleAway switches the selected row's structure between home and away
+func (d *dashboard) toggleAway() {
+	row := d.selectedRow()
+	if row == nil {
+		return
+	}
+	mode := nest.Away
+	if row.structure.Away != nest.Home {
+		mode = nest.Home
+	}
+	apiErr := row.structure.SetAway(mode)
+	if apiErr != nil {
+		d.status = "Error: " + apiError(apiErr).Error()
+		return
+	}
+	d.status = row.structure.Name + " set to " + mode.String()
+}
+
+// onlineStatus formats whether a device is online
+func onlineStatus(online bool) string {
+	if online {
+		return "online"
+	}
+	return "offline"
+}
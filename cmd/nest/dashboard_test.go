@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jsgoecke/nest"
+	"github.com/jsgoecke/nest/nesttest"
+	"github.com/nsf/termbox-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// texts returns the text of each row
+func texts(rows []*dashboardRow) []string {
+	lines := []string{}
+	for _, row := range rows {
+		lines = append(lines, row.text)
+	}
+	return lines
+}
+
+func TestDashboard(t *testing.T) {
+	server := nesttest.NewHome()
+	defer server.Close()
+	client := server.Client()
+
+	Convey("When showing the dashboard", t, func() {
+		devices, _ := client.Devices()
+		structures, _ := client.Structures()
+		d := &dashboard{devices: devices, structures: structures}
+
+		Convey("Every structure should be listed with its devices", func() {
+			lines := texts(d.render(0))
+			So(lines[0], ShouldStartWith, "Nest")
+			So(lines[1], ShouldEqual, "> Home  [home]")
+			So(lines[2], ShouldEqual, "    Hallway Thermostat           online  66°F, heat to 68°F, heating")
+			So(lines[3], ShouldEqual, "    Kitchen Protect              online  smoke ok, co ok, battery ok")
+			So(lines[4], ShouldEqual, "    Porch Camera                 offline not streaming")
+			So(len(lines), ShouldEqual, 6)
+		})
+		Convey("Rows should be cut to fit the terminal", func() {
+			So(len(d.render(3)), ShouldEqual, 3)
+		})
+		Convey("An empty account should say so", func() {
+			So(texts((&dashboard{}).render(0))[1], ShouldEqual, "No structures")
+		})
+		Convey("Arrow keys should move the selection within the rows", func() {
+			d.handleKey(termbox.KeyArrowUp, 0)
+			So(d.selected, ShouldEqual, "s1")
+			d.handleKey(0, 'j')
+			d.handleKey(termbox.KeyArrowDown, 0)
+			d.handleKey(termbox.KeyArrowDown, 0)
+			d.handleKey(termbox.KeyArrowDown, 0)
+			So(d.selected, ShouldEqual, "c1")
+			So(d.render(0)[4].selected, ShouldBeTrue)
+		})
+		Convey("The selection should stay on the same device when the rows change", func() {
+			d.handleKey(0, 'j')
+			devices.Thermostats["t0"] = &nest.Thermostat{DeviceID: "t0", StructureID: "s1", NameLong: "Attic Thermostat"}
+			structures["s1"].Thermostats = append(structures["s1"].Thermostats, "t0")
+			So(d.selectedRow().thermostat.DeviceID, ShouldEqual, "t1")
+			So(d.render(0)[3].selected, ShouldBeTrue)
+
+			delete(devices.Thermostats, "t1")
+			So(d.selectedRow(), ShouldBeNil)
+			d.handleKey(0, '+')
+			So(d.status, ShouldEqual, "Select a thermostat to adjust its target")
+			d.handleKey(0, 'k')
+			So(d.selected, ShouldEqual, "s1")
+		})
+		Convey("Plus and minus should adjust the selected thermostat", func() {
+			d.handleKey(0, '+')
+			So(d.status, ShouldEqual, "Select a thermostat to adjust its target")
+			d.handleKey(0, 'j')
+			d.handleKey(0, '+')
+			So(d.status, ShouldEqual, "Hallway Thermostat target set to 69°F")
+			thermostat := &nest.Thermostat{}
+			server.Get("devices/thermostats/t1", thermostat)
+			So(thermostat.TargetTemperatureF, ShouldEqual, 69)
+			d.handleKey(0, '-')
+			d.handleKey(0, '-')
+			server.Get("devices/thermostats/t1", thermostat)
+			So(thermostat.TargetTemperatureF, ShouldEqual, 67)
+		})
+		Convey("Errors from the Nest API should be shown", func() {
+			server.FailNext("PUT", "/devices/thermostats/t1", 503, "service_unavailable")
+			d.handleKey(0, 'j')
+			d.handleKey(0, '+')
+			So(d.status, ShouldEqual, "Error: api_error: service_unavailable")
+		})
+		Convey("The away key should toggle the structure", func() {
+			d.handleKey(0, 'a')
+			So(d.status, ShouldEqual, "Home set to away")
+			So(strings.Contains(d.render(0)[1].text, "[away]"), ShouldBeTrue)
+			d.handleKey(0, 'a')
+			So(structures["s1"].Away, ShouldEqual, nest.Home)
+		})
+		Convey("Quitting should close the dashboard", func() {
+			So(d.handleKey(0, 'q'), ShouldBeFalse)
+			So(d.handleKey(termbox.KeyEsc, 0), ShouldBeFalse)
+			So(d.handleKey(0, 'x'), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,227 @@
+/*
+Command nest inspects and controls Nest homes from the command line.
+
+	nest auth -client-id <id> -client-secret <secret> -code <authorization-code>
+	nest devices [-json]
+	nest structures [-json]
+	nest get <thermostat> [field]
+	nest set <thermostat> <field>=<value>...
+	nest away <structure> home|away
+	nest eta <structure> <trip-id> <arrival-in>|cancel
+	nest watch [-structures] [-json] [-count n]
+	nest dashboard
+
+The fields set accepts are target, target_high, target_low, hvac_mode, label, temperature_scale,
+fan_timer_active and fan_timer_duration. Temperatures may be given as 72, 72F or 21.5C.
+The dashboard shows every structure and device live, with keys to adjust setpoints and toggle away.
+Thermostats and structures may be given by ID or by name. The token is read from the -token flag,
+the NEST_TOKEN environment variable or the config file written by auth, in that order.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jsgoecke/nest"
+)
+
+// Config is what auth saves for later commands
+type Config struct {
+	Token  string `json:"token"`
+	APIURL string `json:"api_url,omitempty"`
+}
+
+// cli runs one command, writing its output to stdout
+type cli struct {
+	stdout     io.Writer
+	stderr     io.Writer
+	token      string
+	apiURL     string
+	configPath string
+}
+
+// command is a subcommand of the CLI
+type command struct {
+	usage string
+	run   func(c *cli, flags *flag.FlagSet, args []string) error
+}
+
+// usageError is an error in how a command was called
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// commands are the subcommands by name
+var commands = map[string]*command{
+	"auth":       {usage: "auth -client-id <id> -client-secret <secret> -code <authorization-code>", run: (*cli).auth},
+	"devices":    {usage: "devices [-json]", run: (*cli).devices},
+	"structures": {usage: "structures [-json]", run: (*cli).structures},
+	"get":        {usage: "get <thermostat> [field]", run: (*cli).get},
+	"set":        {usage: "set <thermostat> <field>=<value>...", run: (*cli).set},
+	"away":       {usage: "away <structure> home|away", run: (*cli).away},
+	"eta":        {usage: "eta [-window 10m] <structure> <trip-id> <arrival-in>|cancel", run: (*cli).eta},
+	"watch":      {usage: "watch [-structures] [-json] [-count n]", run: (*cli).watch},
+	"dashboard":  {usage: "dashboard", run: (*cli).dashboard},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command named by the first argument, returning the exit code
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || commands[args[0]] == nil {
+		printUsage(stderr)
+		return 2
+	}
+	c := &cli{stdout: stdout, stderr: stderr}
+	cmd := commands[args[0]]
+	flags := flag.NewFlagSet("nest "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&c.token, "token", "", "access token, instead of NEST_TOKEN or the saved token")
+	flags.StringVar(&c.apiURL, "api-url", "", "URL of the Nest API")
+	flags.StringVar(&c.configPath, "config", defaultConfigPath(), "file the token is saved to")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: nest "+cmd.usage)
+		flags.PrintDefaults()
+	}
+	err := cmd.run(c, flags, args[1:])
+	if err == flag.ErrHelp {
+		return 2
+	}
+	if usage, ok := err.(*usageError); ok {
+		fmt.Fprintln(stderr, "nest: "+usage.message)
+		fmt.Fprintln(stderr, "usage: nest "+cmd.usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "nest: "+err.Error())
+		return 1
+	}
+	return 0
+}
+
+// printUsage lists the commands
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "usage: nest <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, name := range names {
+		fmt.Fprintln(w, "  nest "+commands[name].usage)
+	}
+}
+
+// client creates a Nest client from the flags, environment and saved config
+func (c *cli) client() (*nest.Client, error) {
+	config, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	client := nest.New("", "", "", "")
+	client.Token = c.token
+	if client.Token == "" {
+		client.Token = os.Getenv("NEST_TOKEN")
+	}
+	if client.Token == "" {
+		client.Token = config.Token
+	}
+	if client.Token == "" {
+		return nil, errors.New("no token, run nest auth or set NEST_TOKEN")
+	}
+	if config.APIURL != "" {
+		client.APIURL = config.APIURL
+	}
+	if c.apiURL != "" {
+		client.APIURL = c.apiURL
+	}
+	return client, nil
+}
+
+// loadConfig reads the saved config, returning an empty one if there is none
+func (c *cli) loadConfig() (*Config, error) {
+	config := &Config{}
+	data, err := ioutil.ReadFile(c.configPath)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(data, config)
+	}
+	if err != nil {
+		return nil, errors.New("reading config " + c.configPath + ": " + err.Error())
+	}
+	return config, nil
+}
+
+// saveConfig writes the config readable only by the user
+func (c *cli) saveConfig(config *Config) error {
+	err := os.MkdirAll(filepath.Dir(c.configPath), 0700)
+	if err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(config, "", "  ")
+	return ioutil.WriteFile(c.configPath, append(data, '\n'), 0600)
+}
+
+// defaultConfigPath returns where the config is kept unless -config is given
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "nest", "config.json")
+}
+
+// apiError turns a Nest API error into an error, keeping nil as nil
+func apiError(err *nest.APIError) error {
+	if err == nil {
+		return nil
+	}
+	if err.Description == "" {
+		return errors.New(err.Error)
+	}
+	return errors.New(err.Error + ": " + err.Description)
+}
+
+// findThermostat looks a thermostat up by ID or case-insensitive name
+func findThermostat(devices *nest.Devices, key string) (*nest.Thermostat, error) {
+	if thermostat, ok := devices.Thermostats[key]; ok {
+		return thermostat, nil
+	}
+	for _, thermostat := range devices.Thermostats {
+		if strings.EqualFold(thermostat.Name, key) || strings.EqualFold(thermostat.NameLong, key) {
+			return thermostat, nil
+		}
+	}
+	return nil, errors.New("no thermostat " + key)
+}
+
+// findStructure looks a structure up by ID or case-insensitive name
+func findStructure(structures map[string]*nest.Structure, key string) (*nest.Structure, error) {
+	if structure, ok := structures[key]; ok {
+		return structure, nil
+	}
+	for _, structure := range structures {
+		if strings.EqualFold(structure.Name, key) {
+			return structure, nil
+		}
+	}
+	return nil, errors.New("no structure " + key)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jsgoecke/nest"
+	"github.com/jsgoecke/nest/nesttest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// runCLI runs the CLI against the server, returning the exit code and output
+func runCLI(server *nesttest.Server, args ...string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	args = append([]string{args[0], "-api-url", server.URL, "-token", server.Token}, args[1:]...)
+	code := run(args, stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLI(t *testing.T) {
+	os.Unsetenv("NEST_TOKEN")
+	server := nesttest.NewHome()
+	defer server.Close()
+
+	Convey("When running without a command the usage should be shown", t, func() {
+		stderr := &bytes.Buffer{}
+		So(run(nil, &bytes.Buffer{}, stderr), ShouldEqual, 2)
+		So(stderr.String(), ShouldContainSubstring, "nest watch")
+	})
+
+	Convey("When authorizing the token should be saved for later commands", t, func() {
+		dir, _ := os.MkdirTemp("", "nest")
+		defer os.RemoveAll(dir)
+		config := filepath.Join(dir, "nest", "config.json")
+		stdout := &bytes.Buffer{}
+		code := run([]string{"auth", "-config", config, "-api-url", server.URL, "-access-token-url", server.AccessTokenURL,
+			"-client-id", "id", "-client-secret", "secret", "-code", server.AuthorizationCode}, stdout, &bytes.Buffer{})
+		So(code, ShouldEqual, 0)
+		So(stdout.String(), ShouldContainSubstring, "Token saved to "+config)
+		info, _ := os.Stat(config)
+		So(info.Mode().Perm(), ShouldEqual, 0600)
+
+		stdout.Reset()
+		So(run([]string{"structures", "-config", config}, stdout, &bytes.Buffer{}), ShouldEqual, 0)
+		So(stdout.String(), ShouldContainSubstring, "Home")
+
+		stderr := &bytes.Buffer{}
+		So(run([]string{"devices", "-config", filepath.Join(dir, "missing.json")}, stdout, stderr), ShouldEqual, 1)
+		So(stderr.String(), ShouldContainSubstring, "no token")
+
+		broken := filepath.Join(dir, "broken.json")
+		os.WriteFile(broken, []byte("{\"token\":"), 0600)
+		stderr.Reset()
+		So(run([]string{"devices", "-config", broken}, stdout, stderr), ShouldEqual, 1)
+		So(stderr.String(), ShouldContainSubstring, "reading config "+broken+": unexpected end of JSON input")
+	})
+
+	Convey("When listing devices and structures", t, func() {
+		code, stdout, _ := runCLI(server, "devices")
+		So(code, ShouldEqual, 0)
+		So(stdout, ShouldContainSubstring, "thermostat      t1  Hallway Thermostat  yes     66°F, heat to 68°F, heating")
+		So(stdout, ShouldContainSubstring, "smoke ok, co ok, battery ok")
+
+		code, stdout, _ = runCLI(server, "devices", "-json")
+		So(code, ShouldEqual, 0)
+		devices := &nest.Devices{}
+		So(json.Unmarshal([]byte(stdout), devices), ShouldBeNil)
+		So(devices.Thermostats["t1"].Name, ShouldEqual, "Hallway")
+		So(stdout, ShouldNotContainSubstring, server.Token)
+
+		code, stdout, _ = runCLI(server, "structures")
+		So(code, ShouldEqual, 0)
+		So(stdout, ShouldContainSubstring, "s1  Home  home  1            1                1")
+	})
+
+	Convey("When getting and setting thermostat fields", t, func() {
+		code, stdout, _ := runCLI(server, "get", "hallway", "hvac_mode")
+		So(code, ShouldEqual, 0)
+		So(stdout, ShouldEqual, "heat\n")
+
+		code, stdout, _ = runCLI(server, "set", "t1", "hvac_mode=heat-cool", "target_low=64", "target_high=24C")
+		So(code, ShouldEqual, 0)
+		So(stdout, ShouldContainSubstring, "heat-cool 64°F-75°F")
+		thermostat := &nest.Thermostat{}
+		server.Get("devices/thermostats/t1", thermostat)
+		So(thermostat.HvacMode, ShouldEqual, nest.HeatCool)
+		So(thermostat.TargetTemperatureHighF, ShouldEqual, 75)
+
+		code, _, stderr := runCLI(server, "set", "t1", "humidity=40")
+		So(code, ShouldEqual, 2)
+		So(stderr, ShouldContainSubstring, "cannot set humidity")
+
+		code, _, stderr = runCLI(server, "set", "t1", "target=95")
+		So(code, ShouldEqual, 1)
+		So(stderr, ShouldContainSubstring, "Temperature must be between 50 and 90 Farenheit")
+
+		code, _, stderr = runCLI(server, "get", "attic")
+		So(code, ShouldEqual, 1)
+		So(stderr, ShouldContainSubstring, "no thermostat attic")
+	})
+
+	Convey("When setting away and ETAs", t, func() {
+		code, stdout, _ := runCLI(server, "away", "home", "away")
+		So(code, ShouldEqual, 0)
+		So(stdout, ShouldEqual, "Home is away\n")
+
+		code, _, _ = runCLI(server, "eta", "s1", "trip-1", "20m")
+		So(code, ShouldEqual, 0)
+		eta := &nest.ETA{}
+		server.Get("structures/s1/eta", eta)
+		So(eta.TripID, ShouldEqual, "trip-1")
+
+		code, stdout, _ = runCLI(server, "eta", "s1", "trip-1", "cancel")
+		So(code, ShouldEqual, 0)
+		So(stdout, ShouldEqual, "Cancelled trip trip-1\n")
+
+		code, _, _ = runCLI(server, "eta", "s1", "trip-1", "soon")
+		So(code, ShouldEqual, 2)
+	})
+
+	Convey("When watching the stream it should print updates", t, func() {
+		code, stdout, _ := runCLI(server, "watch", "-json", "-count", "1")
+		So(code, ShouldEqual, 0)
+		devices := &nest.Devices{}
+		So(json.Unmarshal([]byte(stdout), devices), ShouldBeNil)
+		So(devices.SmokeCoAlarms["a1"].NameLong, ShouldEqual, "Kitchen Protect")
+
+		code, stdout, _ = runCLI(server, "watch", "-structures", "-count", "1")
+		So(code, ShouldEqual, 0)
+		So(stdout, ShouldContainSubstring, "AWAY")
+	})
+}
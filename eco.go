@@ -0,0 +1,84 @@
+package nest
+
+import (
+	"encoding/json"
+)
+
+/*
+EnterEco switches the thermostat to eco mode, remembering the current HvacMode
+https://developer.nest.com/documentation/api#hvac_mode
+
+	t.EnterEco()
+*/
+func (t *Thermostat) EnterEco() *APIError {
+	if t.HvacMode == Eco {
+		return nil
+	}
+	previous := t.HvacMode
+	apiErr := t.SetHvacMode(Eco)
+	if apiErr != nil {
+		return apiErr
+	}
+	t.PreviousHvacMode = previous
+	return nil
+}
+
+/*
+LeaveEco switches the thermostat out of eco mode, restoring the previous HvacMode
+https://developer.nest.com/documentation/api#previous_hvac_mode
+
+	t.LeaveEco()
+*/
+func (t *Thermostat) LeaveEco() *APIError {
+	if t.HvacMode != Eco {
+		return generateError(ModeError, "Thermostat is not in eco mode")
+	}
+	if !t.PreviousHvacMode.Valid() || t.PreviousHvacMode == Eco {
+		return generateError(ModeError, "Thermostat has no previous HvacMode to restore")
+	}
+	return t.SetHvacMode(t.PreviousHvacMode)
+}
+
+// EcoHigh returns the high eco temperature in the thermostat's scale
+func (t *Thermostat) EcoHigh() Temperature {
+	return t.temperature(t.EcoTemperatureHighF, t.EcoTemperatureHighC)
+}
+
+// EcoLow returns the low eco temperature in the thermostat's scale
+func (t *Thermostat) EcoLow() Temperature {
+	return t.temperature(t.EcoTemperatureLowF, t.EcoTemperatureLowC)
+}
+
+// UnmarshalJSON decodes a thermostat, keeping the eco and deprecated away temperatures in sync
+func (t *Thermostat) UnmarshalJSON(data []byte) error {
+	type thermostat Thermostat
+	err := json.Unmarshal(data, (*thermostat)(t))
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]json.RawMessage)
+	json.Unmarshal(data, &fields)
+	switch {
+	case hasAnyField(fields, "eco_temperature_high_f", "eco_temperature_high_c", "eco_temperature_low_f", "eco_temperature_low_c"):
+		t.AwayTemperatureHighF = t.EcoTemperatureHighF
+		t.AwayTemperatureHighC = t.EcoTemperatureHighC
+		t.AwayTemperatureLowF = t.EcoTemperatureLowF
+		t.AwayTemperatureLowC = t.EcoTemperatureLowC
+	case hasAnyField(fields, "away_temperature_high_f", "away_temperature_high_c", "away_temperature_low_f", "away_temperature_low_c"):
+		t.EcoTemperatureHighF = t.AwayTemperatureHighF
+		t.EcoTemperatureHighC = t.AwayTemperatureHighC
+		t.EcoTemperatureLowF = t.AwayTemperatureLowF
+		t.EcoTemperatureLowC = t.AwayTemperatureLowC
+	}
+	return nil
+}
+
+// hasAnyField reports whether any of the names are keys of the decoded JSON object
+func hasAnyField(fields map[string]json.RawMessage, names ...string) bool {
+	for _, name := range names {
+		if _, ok := fields[name]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package nest
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestEcoTemperatures(t *testing.T) {
+	Convey("Given a thermostat with eco temperatures", t, func() {
+		thermostat := &Thermostat{}
+		err := json.Unmarshal(ecoThermostatJSON(), thermostat)
+		So(err, ShouldBeNil)
+		So(thermostat.HvacMode, ShouldEqual, Eco)
+		So(thermostat.PreviousHvacMode, ShouldEqual, Heat)
+		So(thermostat.EcoTemperatureLowF, ShouldEqual, 55)
+		So(thermostat.AwayTemperatureLowF, ShouldEqual, 55)
+		So(thermostat.AwayTemperatureHighC, ShouldEqual, 26.5)
+		So(thermostat.EcoHigh(), ShouldResemble, TempF(80))
+	})
+
+	Convey("Given a thermostat with only away temperatures", t, func() {
+		combined := &Combined{}
+		json.Unmarshal(combinedJSON(), combined)
+		thermostat := combined.Devices.Thermostats["peyiJNo0IldT2YlIVtYaGQ"]
+		So(thermostat.EcoTemperatureHighF, ShouldEqual, 72)
+		So(thermostat.EcoTemperatureLowC, ShouldEqual, 17.5)
+		So(thermostat.EcoLow(), ShouldResemble, TempC(17.5))
+	})
+}
+
+func TestEcoMode(t *testing.T) {
+	Convey("When entering and leaving eco mode", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.APIURL = ts.URL
+		devices, _ := client.Devices()
+		client.RedirectURL = ts.URL
+		thermostat := devices.Thermostats["z1234"]
+		Convey("Entering eco should set the HvacMode", func() {
+			err := thermostat.EnterEco()
+			So(err, ShouldBeNil)
+			So(thermostat.HvacMode, ShouldEqual, Eco)
+		})
+		Convey("Entering and then leaving eco should restore the mode it left", func() {
+			thermostat.HvacMode = Cool
+			thermostat.PreviousHvacMode = ""
+			So(thermostat.EnterEco(), ShouldBeNil)
+			So(thermostat.PreviousHvacMode, ShouldEqual, Cool)
+			So(thermostat.LeaveEco(), ShouldBeNil)
+			So(thermostat.HvacMode, ShouldEqual, Cool)
+		})
+		Convey("Leaving eco should restore the previous HvacMode", func() {
+			thermostat.HvacMode = Eco
+			thermostat.PreviousHvacMode = HeatCool
+			err := thermostat.LeaveEco()
+			So(err, ShouldBeNil)
+			So(thermostat.HvacMode, ShouldEqual, HeatCool)
+		})
+		Convey("Leaving eco without a previous HvacMode should fail", func() {
+			thermostat.HvacMode = Eco
+			err := thermostat.LeaveEco()
+			So(err.Description, ShouldEqual, "Thermostat has no previous HvacMode to restore")
+		})
+		Convey("Leaving eco when not in eco should fail", func() {
+			err := thermostat.LeaveEco()
+			So(err.Error, ShouldEqual, ModeError)
+		})
+		Convey("Target temperatures should be rejected in eco", func() {
+			thermostat.HvacMode = Eco
+			err := thermostat.SetTargetTempF(70)
+			So(err.Error, ShouldEqual, ModeError)
+			err = thermostat.SetTargetTempHighLowF(75, 65)
+			So(err.Error, ShouldEqual, ModeError)
+		})
+	})
+}
+
+func ecoThermostatJSON() []byte {
+	return []byte(`
+		{
+		    "device_id": "peyiJNo0IldT2YlIVtYaGQ",
+		    "hvac_mode": "eco",
+		    "previous_hvac_mode": "heat",
+		    "temperature_scale": "F",
+		    "eco_temperature_high_f": 80,
+		    "eco_temperature_high_c": 26.5,
+		    "eco_temperature_low_f": 55,
+		    "eco_temperature_low_c": 12.5
+		}`)
+}
@@ -0,0 +1,386 @@
+/*
+Package exporter serves the state of Nest thermostats, smoke and CO alarms, cameras and structures
+as Prometheus metrics, kept current from the Nest REST streaming API.
+
+	client := nest.New(ClientID, State, ClientSecret, AuthorizationCode)
+	client.Token = Token
+	e := exporter.New(client)
+	err := e.Start()
+	http.Handle("/metrics", e)
+	http.ListenAndServe(":9264", nil)
+*/
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsgoecke/nest"
+)
+
+const (
+	// DevicesStream labels metrics about the devices stream
+	DevicesStream = "devices"
+	// StructuresStream labels metrics about the structures stream
+	StructuresStream = "structures"
+	// DefaultRetryDelay is how long to wait before reconnecting a stream that failed
+	DefaultRetryDelay = 5 * time.Second
+)
+
+// Exporter keeps the latest devices and structures and writes them out as metrics
+type Exporter struct {
+	Client     *nest.Client
+	RetryDelay time.Duration
+	mu         sync.Mutex
+	devices    *nest.Devices
+	structures map[string]*nest.Structure
+	updates    map[string]int
+	lastUpdate map[string]time.Time
+	reconnects map[string]int
+	apiErrors  map[string]int
+	cancel     context.CancelFunc
+}
+
+/*
+New creates an Exporter for the client. The client's HTTPClient is wrapped so that API errors
+can be counted.
+
+	e := exporter.New(client)
+*/
+func New(client *nest.Client) *Exporter {
+	e := &Exporter{
+		Client:     client,
+		RetryDelay: DefaultRetryDelay,
+		updates:    make(map[string]int),
+		lastUpdate: make(map[string]time.Time),
+		reconnects: make(map[string]int),
+		apiErrors:  make(map[string]int),
+	}
+	base := http.DefaultTransport
+	httpClient := &http.Client{}
+	if client.HTTPClient != nil {
+		*httpClient = *client.HTTPClient
+		if client.HTTPClient.Transport != nil {
+			base = client.HTTPClient.Transport
+		}
+	}
+	httpClient.Transport = &countingTransport{exporter: e, base: base}
+	client.HTTPClient = httpClient
+	return e
+}
+
+/*
+Start fetches the devices and structures, then keeps their streams open in the background until
+Stop is called. A stream that fails, is refused or ends is reconnected after RetryDelay, waiting
+twice as long for each failure in a row.
+
+	err := e.Start()
+	defer e.Stop()
+*/
+func (e *Exporter) Start() *nest.APIError {
+	devices, apiErr := e.Client.Devices()
+	if apiErr != nil {
+		return apiErr
+	}
+	e.UpdateDevices(devices, time.Now())
+	structures, apiErr := e.Client.Structures()
+	if apiErr != nil {
+		return apiErr
+	}
+	e.UpdateStructures(structures, time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	e.mu.Lock()
+	e.cancel = cancel
+	e.mu.Unlock()
+	go e.Client.WatchDevices(ctx, e.RetryDelay, func(devices *nest.Devices, err error) {
+		if err != nil {
+			e.reconnect(DevicesStream)
+			return
+		}
+		e.UpdateDevices(devices, time.Now())
+	})
+	go e.Client.WatchStructures(ctx, e.RetryDelay, func(structures map[string]*nest.Structure, err error) {
+		if err != nil {
+			e.reconnect(StructuresStream)
+			return
+		}
+		e.UpdateStructures(structures, time.Now())
+	})
+	return nil
+}
+
+// Stop closes the streams opened by Start
+func (e *Exporter) Stop() {
+	e.mu.Lock()
+	cancel := e.cancel
+	e.cancel = nil
+	e.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// reconnect counts a stream that is about to be connected again
+func (e *Exporter) reconnect(stream string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.reconnects[stream]++
+}
+
+// UpdateDevices replaces the devices the metrics are written from
+func (e *Exporter) UpdateDevices(devices *nest.Devices, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.devices = devices
+	e.updates[DevicesStream]++
+	e.lastUpdate[DevicesStream] = now
+}
+
+// UpdateStructures replaces the structures the metrics are written from
+func (e *Exporter) UpdateStructures(structures map[string]*nest.Structure, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.structures = structures
+	e.updates[StructuresStream]++
+	e.lastUpdate[StructuresStream] = now
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.WriteMetrics(w)
+}
+
+// WriteMetrics writes the metrics in the Prometheus text format
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m := &metrics{families: make(map[string]*family)}
+	e.structureMetrics(m)
+	e.thermostatMetrics(m)
+	e.smokeCoAlarmMetrics(m)
+	e.cameraMetrics(m)
+	e.exporterMetrics(m)
+	_, err := w.Write(m.bytes())
+	return err
+}
+
+// structureName returns the name of a structure by ID, falling back to the ID
+func (e *Exporter) structureName(id string) string {
+	if structure, ok := e.structures[id]; ok && structure.Name != "" {
+		return structure.Name
+	}
+	return id
+}
+
+// structureMetrics adds the away state and alarm levels of each structure
+func (e *Exporter) structureMetrics(m *metrics) {
+	for _, id := range sortedIDs(e.structures) {
+		s := e.structures[id]
+		labels := []string{"structure_id", id, "structure", e.structureName(id)}
+		m.add("nest_structure_away", "Whether the structure is away, 1 for away or auto-away.", "gauge", labels, boolValue(s.Away == nest.Away || s.Away == nest.AutoAway))
+		m.add("nest_structure_co_alarm_level", "CO alarm state of the structure, 0 ok, 1 warning, 2 emergency.", "gauge", labels, float64(s.CoAlarmState.Level()))
+		m.add("nest_structure_smoke_alarm_level", "Smoke alarm state of the structure, 0 ok, 1 warning, 2 emergency.", "gauge", labels, float64(s.SmokeAlarmState.Level()))
+	}
+}
+
+// thermostatMetrics adds temperatures, humidity, modes and states of each thermostat
+func (e *Exporter) thermostatMetrics(m *metrics) {
+	if e.devices == nil {
+		return
+	}
+	for _, id := range sortedIDs(e.devices.Thermostats) {
+		t := e.devices.Thermostats[id]
+		labels := e.deviceLabels(id, t.StructureID, t.NameLong, t.WhereName)
+		m.add("nest_device_online", "Whether the device is online.", "gauge", append(labels, "type", "thermostat"), boolValue(t.IsOnline))
+		m.add("nest_thermostat_ambient_temperature_celsius", "Ambient temperature measured by the thermostat.", "gauge", labels, float64(t.AmbientTemperatureC))
+		m.add("nest_thermostat_target_temperature_celsius", "Target temperature of the thermostat in heat or cool mode.", "gauge", labels, float64(t.TargetTemperatureC))
+		m.add("nest_thermostat_target_temperature_high_celsius", "High target temperature of the thermostat in heat-cool mode.", "gauge", labels, float64(t.TargetTemperatureHighC))
+		m.add("nest_thermostat_target_temperature_low_celsius", "Low target temperature of the thermostat in heat-cool mode.", "gauge", labels, float64(t.TargetTemperatureLowC))
+		m.add("nest_thermostat_humidity_percent", "Relative humidity measured by the thermostat.", "gauge", labels, float64(t.Humidity))
+		m.add("nest_thermostat_fan_timer_active", "Whether the fan timer is running.", "gauge", labels, boolValue(t.FanTimerActive))
+		for _, mode := range []nest.HvacMode{nest.Heat, nest.Cool, nest.HeatCool, nest.Eco, nest.Off} {
+			m.add("nest_thermostat_hvac_mode", "HVAC mode of the thermostat, 1 for the current mode.", "gauge", append(labels, "mode", mode.String()), boolValue(t.HvacMode == mode))
+		}
+		for _, state := range []nest.HvacState{nest.Heating, nest.Cooling, nest.Idle} {
+			m.add("nest_thermostat_hvac_state", "What the HVAC system is doing, 1 for the current state.", "gauge", append(labels, "state", state.String()), boolValue(t.HvacState == state))
+		}
+	}
+}
+
+// smokeCoAlarmMetrics adds alarm levels and battery health of each smokecoalarm
+func (e *Exporter) smokeCoAlarmMetrics(m *metrics) {
+	if e.devices == nil {
+		return
+	}
+	for _, id := range sortedIDs(e.devices.SmokeCoAlarms) {
+		a := e.devices.SmokeCoAlarms[id]
+		labels := e.deviceLabels(id, a.StructureID, a.NameLong, a.WhereName)
+		m.add("nest_device_online", "Whether the device is online.", "gauge", append(labels, "type", "smoke_co_alarm"), boolValue(a.IsOnline))
+		m.add("nest_smoke_co_alarm_co_level", "CO alarm state, 0 ok, 1 warning, 2 emergency.", "gauge", labels, float64(a.CoAlarmState.Level()))
+		m.add("nest_smoke_co_alarm_smoke_level", "Smoke alarm state, 0 ok, 1 warning, 2 emergency.", "gauge", labels, float64(a.SmokeAlarmState.Level()))
+		m.add("nest_smoke_co_alarm_battery_replace", "Whether the battery needs replacing.", "gauge", labels, boolValue(a.NeedsBattery()))
+	}
+}
+
+// cameraMetrics adds the online and streaming status of each camera
+func (e *Exporter) cameraMetrics(m *metrics) {
+	if e.devices == nil {
+		return
+	}
+	for _, id := range sortedIDs(e.devices.Cameras) {
+		c := e.devices.Cameras[id]
+		labels := e.deviceLabels(id, c.StructureID, c.NameLong, c.WhereName)
+		m.add("nest_device_online", "Whether the device is online.", "gauge", append(labels, "type", "camera"), boolValue(c.IsOnline))
+		m.add("nest_camera_streaming", "Whether the camera is streaming.", "gauge", labels, boolValue(c.IsStreaming))
+	}
+}
+
+// exporterMetrics adds the counters about the streams and the Nest API
+func (e *Exporter) exporterMetrics(m *metrics) {
+	for _, stream := range []string{DevicesStream, StructuresStream} {
+		labels := []string{"stream", stream}
+		m.add("nest_stream_reconnects_total", "Times the stream failed, was refused or ended and was connected again.", "counter", labels, float64(e.reconnects[stream]))
+		m.add("nest_stream_updates_total", "Updates received from the stream.", "counter", labels, float64(e.updates[stream]))
+		if last, ok := e.lastUpdate[stream]; ok {
+			m.add("nest_stream_last_update_timestamp_seconds", "When the last update was received from the stream.", "gauge", labels, float64(last.Unix()))
+		}
+	}
+	codes := make([]string, 0, len(e.apiErrors))
+	for code := range e.apiErrors {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	m.describe("nest_api_errors_total", "Failed requests to the Nest API by status code, or error for requests that got no response.", "counter")
+	for _, code := range codes {
+		m.add("nest_api_errors_total", "", "counter", []string{"code", code}, float64(e.apiErrors[code]))
+	}
+}
+
+// deviceLabels returns the labels identifying a device
+func (e *Exporter) deviceLabels(id string, structureID string, name string, where string) []string {
+	return []string{"device_id", id, "name", name, "structure", e.structureName(structureID), "where", where}
+}
+
+// countingTransport counts API errors on the way through
+type countingTransport struct {
+	exporter *Exporter
+	base     http.RoundTripper
+}
+
+// RoundTrip sends the request, counting it if it fails other than by being cancelled
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	e := t.exporter
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		if req.Context().Err() == nil {
+			e.apiErrors["error"]++
+		}
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		e.apiErrors[strconv.Itoa(resp.StatusCode)]++
+	}
+	return resp, nil
+}
+
+// metrics collects samples by family so each family is written together
+type metrics struct {
+	names    []string
+	families map[string]*family
+}
+
+// family is a metric name with its help, type and samples
+type family struct {
+	help    string
+	kind    string
+	samples []string
+}
+
+// describe adds a family without samples, so it is written even when empty
+func (m *metrics) describe(name string, help string, kind string) *family {
+	f, ok := m.families[name]
+	if !ok {
+		f = &family{help: help, kind: kind}
+		m.families[name] = f
+		m.names = append(m.names, name)
+	}
+	return f
+}
+
+// add adds a sample with labels given as name, value pairs
+func (m *metrics) add(name string, help string, kind string, labels []string, value float64) {
+	f := m.describe(name, help, kind)
+	pairs := []string{}
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+escapeLabel(labels[i+1])+`"`)
+	}
+	sample := name
+	if len(pairs) > 0 {
+		sample += "{" + strings.Join(pairs, ",") + "}"
+	}
+	f.samples = append(f.samples, sample+" "+strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// bytes formats every family in the order they were added
+func (m *metrics) bytes() []byte {
+	buffer := &bytes.Buffer{}
+	for _, name := range m.names {
+		f := m.families[name]
+		fmt.Fprintf(buffer, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.kind)
+		for _, sample := range f.samples {
+			buffer.WriteString(sample + "\n")
+		}
+	}
+	return buffer.Bytes()
+}
+
+// escapeLabel escapes a label value for the Prometheus text format
+func escapeLabel(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+	return strings.Replace(value, "\n", `\n`, -1)
+}
+
+// boolValue turns a bool into a gauge value
+func boolValue(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// sortedIDs returns the keys of a map of devices or structures in order
+func sortedIDs(m interface{}) []string {
+	ids := []string{}
+	switch m := m.(type) {
+	case map[string]*nest.Thermostat:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*nest.SmokeCoAlarm:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*nest.Camera:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*nest.Structure:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
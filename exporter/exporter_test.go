@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsgoecke/nest"
+	"github.com/jsgoecke/nest/nesttest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// scrape returns the metrics as served over HTTP
+func scrape(e *Exporter) string {
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	return w.Body.String()
+}
+
+// waitFor scrapes until the metrics contain the line, giving up after a few seconds
+func waitFor(e *Exporter, line string) string {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		metrics := scrape(e)
+		if strings.Contains(metrics, line) || time.Now().After(deadline) {
+			return metrics
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	Convey("When exporting a home from the stream", t, func() {
+		server := nesttest.NewHome()
+		defer server.Close()
+		server.Set("devices/smoke_co_alarms/a1/smoke_alarm_state", "warning")
+		server.Set("devices/smoke_co_alarms/a1/battery_health", "replace")
+		server.Set("structures/s1/smoke_alarm_state", "warning")
+		server.Set("structures/s1/away", "away")
+		e := New(server.Client())
+		e.RetryDelay = 20 * time.Millisecond
+		So(e.Start(), ShouldBeNil)
+		defer e.Stop()
+		labels := `device_id="t1",name="Hallway Thermostat",structure="Home",where="Hallway"`
+		metrics := waitFor(e, "nest_thermostat_ambient_temperature_celsius{"+labels+"} 19")
+
+		Convey("Thermostats should be exported with their labels", func() {
+			So(metrics, ShouldContainSubstring, "# TYPE nest_thermostat_ambient_temperature_celsius gauge\n")
+			So(metrics, ShouldContainSubstring, "nest_thermostat_ambient_temperature_celsius{"+labels+"} 19\n")
+			So(metrics, ShouldContainSubstring, "nest_thermostat_target_temperature_celsius{"+labels+"} 20\n")
+			So(metrics, ShouldContainSubstring, "nest_thermostat_humidity_percent{"+labels+"} 40\n")
+			So(metrics, ShouldContainSubstring, "nest_thermostat_fan_timer_active{"+labels+"} 0\n")
+			So(metrics, ShouldContainSubstring, "nest_thermostat_hvac_mode{"+labels+`,mode="heat"} 1`+"\n")
+			So(metrics, ShouldContainSubstring, "nest_thermostat_hvac_mode{"+labels+`,mode="cool"} 0`+"\n")
+			So(metrics, ShouldContainSubstring, "nest_thermostat_hvac_state{"+labels+`,state="heating"} 1`+"\n")
+			So(metrics, ShouldContainSubstring, "nest_device_online{"+labels+`,type="thermostat"} 1`+"\n")
+		})
+		Convey("Alarms, cameras and structures should be exported", func() {
+			alarm := `device_id="a1",name="Kitchen Protect",structure="Home",where="Kitchen"`
+			So(metrics, ShouldContainSubstring, "nest_smoke_co_alarm_smoke_level{"+alarm+"} 1\n")
+			So(metrics, ShouldContainSubstring, "nest_smoke_co_alarm_co_level{"+alarm+"} 0\n")
+			So(metrics, ShouldContainSubstring, "nest_smoke_co_alarm_battery_replace{"+alarm+"} 1\n")
+			camera := `device_id="c1",name="Porch Camera",structure="Home",where="Porch"`
+			So(metrics, ShouldContainSubstring, "nest_device_online{"+camera+`,type="camera"} 0`+"\n")
+			So(metrics, ShouldContainSubstring, "nest_camera_streaming{"+camera+"} 0\n")
+			So(metrics, ShouldContainSubstring, `nest_structure_away{structure_id="s1",structure="Home"} 1`+"\n")
+			So(metrics, ShouldContainSubstring, `nest_structure_smoke_alarm_level{structure_id="s1",structure="Home"} 1`+"\n")
+		})
+		Convey("Each family should be described once", func() {
+			So(strings.Count(metrics, "# TYPE nest_device_online gauge\n"), ShouldEqual, 1)
+			So(metrics, ShouldContainSubstring, "# TYPE nest_api_errors_total counter\n")
+		})
+		Convey("Changes on the stream should update the metrics", func() {
+			server.Set("/devices/thermostats/t1/ambient_temperature_c", 22.5)
+			metrics := waitFor(e, "nest_thermostat_ambient_temperature_celsius{"+labels+"} 22.5")
+			So(metrics, ShouldContainSubstring, "nest_thermostat_ambient_temperature_celsius{"+labels+"} 22.5\n")
+		})
+		Convey("Dropped streams should be counted as reconnects", func() {
+			So(metrics, ShouldContainSubstring, `nest_stream_reconnects_total{stream="devices"} 0`+"\n")
+			waitFor(e, `nest_stream_updates_total{stream="devices"} 2`)
+			waitFor(e, `nest_stream_updates_total{stream="structures"} 2`)
+			server.CloseStreams()
+			metrics := waitFor(e, `nest_stream_reconnects_total{stream="devices"} 1`)
+			So(metrics, ShouldContainSubstring, `nest_stream_reconnects_total{stream="devices"} 1`+"\n")
+			metrics = waitFor(e, `nest_stream_reconnects_total{stream="structures"} 1`)
+			So(metrics, ShouldContainSubstring, `nest_stream_reconnects_total{stream="structures"} 1`+"\n")
+			server.Set("/devices/thermostats/t1/ambient_temperature_c", 23)
+			metrics = waitFor(e, "nest_thermostat_ambient_temperature_celsius{"+labels+"} 23")
+			So(metrics, ShouldContainSubstring, "nest_thermostat_ambient_temperature_celsius{"+labels+"} 23\n")
+		})
+		Convey("A revoked token should be retried with a growing delay", func() {
+			waitFor(e, `nest_stream_updates_total{stream="devices"} 2`)
+			server.RevokeAuth()
+			time.Sleep(500 * time.Millisecond)
+			metrics := scrape(e)
+			So(metrics, ShouldContainSubstring, `nest_api_errors_total{code="401"}`)
+			e.mu.Lock()
+			unauthorized, reconnects := e.apiErrors["401"], e.reconnects[DevicesStream]
+			e.mu.Unlock()
+			So(reconnects, ShouldBeBetweenOrEqual, 2, 8)
+			So(unauthorized, ShouldBeBetweenOrEqual, 2, 16)
+		})
+		Convey("Failed API requests should be counted by status code", func() {
+			server.FailNext("GET", "/devices", 503, "service_unavailable")
+			_, err := e.Client.Devices()
+			So(err.Error, ShouldEqual, "service_unavailable")
+			So(scrape(e), ShouldContainSubstring, `nest_api_errors_total{code="503"} 1`+"\n")
+		})
+	})
+}
+
+func TestWriteMetrics(t *testing.T) {
+	Convey("When writing metrics without a stream", t, func() {
+		e := New(nest.New("", "", "", ""))
+		now := time.Unix(1500000000, 0)
+
+		Convey("Only the stream counters should be written before any update", func() {
+			buffer := &bytes.Buffer{}
+			So(e.WriteMetrics(buffer), ShouldBeNil)
+			So(buffer.String(), ShouldNotContainSubstring, "nest_thermostat")
+			So(buffer.String(), ShouldContainSubstring, `nest_stream_updates_total{stream="devices"} 0`+"\n")
+			So(buffer.String(), ShouldNotContainSubstring, "nest_stream_last_update_timestamp_seconds{")
+		})
+		Convey("Label values should be escaped", func() {
+			e.UpdateDevices(&nest.Devices{Thermostats: map[string]*nest.Thermostat{
+				"t1": {DeviceID: "t1", StructureID: "s1", NameLong: `Jim's "Den"`, WhereName: `Den\Office`},
+			}}, now)
+			e.UpdateStructures(map[string]*nest.Structure{"s1": {StructureID: "s1", Name: "Lake\nHouse"}}, now)
+			buffer := &bytes.Buffer{}
+			e.WriteMetrics(buffer)
+			So(buffer.String(), ShouldContainSubstring, `name="Jim's \"Den\"",structure="Lake\nHouse",where="Den\\Office"`)
+			So(buffer.String(), ShouldContainSubstring, `nest_stream_last_update_timestamp_seconds{stream="devices"} 1.5e+09`+"\n")
+		})
+		Convey("Devices in unknown structures should be labeled by structure ID", func() {
+			e.UpdateDevices(&nest.Devices{SmokeCoAlarms: map[string]*nest.SmokeCoAlarm{
+				"a1": {DeviceID: "a1", StructureID: "s2", CoAlarmState: nest.AlarmEmergency},
+			}}, now)
+			buffer := &bytes.Buffer{}
+			e.WriteMetrics(buffer)
+			So(buffer.String(), ShouldContainSubstring, `nest_smoke_co_alarm_co_level{device_id="a1",name="",structure="s2",where=""} 2`+"\n")
+		})
+	})
+}
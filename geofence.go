@@ -0,0 +1,273 @@
+package nest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultHomeRadius is the distance in meters within which a member is considered home
+	DefaultHomeRadius = 100
+	// DefaultAwayRadius is the distance in meters a member must pass to be considered away again
+	DefaultAwayRadius = 250
+	// DefaultETARadius is the distance in meters within which an approaching member sends an ETA
+	DefaultETARadius = 10000
+	// earthRadius is the mean radius of the earth in meters
+	earthRadius = 6371000
+	// minApproachSpeed is the speed in meters per second below which a member is taken to be stationary
+	minApproachSpeed = 0.5
+	// maxETA is the furthest ahead an estimated arrival is put
+	maxETA = 24 * time.Hour
+)
+
+// GeofenceActionType represents what a Geofence decided to do to its structure
+type GeofenceActionType string
+
+const (
+	// GeofenceSetHome is emitted when the first member arrives home
+	GeofenceSetHome GeofenceActionType = "home"
+	// GeofenceSetAway is emitted when the last member leaves home
+	GeofenceSetAway GeofenceActionType = "away"
+	// GeofenceSetETA is emitted when a member is approaching an empty home
+	GeofenceSetETA GeofenceActionType = "eta"
+	// GeofenceCancelETA is emitted when an approaching member stops or turns back
+	GeofenceCancelETA GeofenceActionType = "cancel-eta"
+)
+
+// Location represents a location update of one member of a structure
+type Location struct {
+	MemberID  string
+	Latitude  float64
+	Longitude float64
+	Time      time.Time
+}
+
+// GeofenceAction represents a change a Geofence wants made to its structure
+type GeofenceAction struct {
+	Type     GeofenceActionType
+	MemberID string
+	Arrival  time.Time
+	Time     time.Time
+}
+
+/*
+Geofence turns member location updates into home, away and ETA changes for a structure.
+A member becomes home inside HomeRadius and only becomes away again past AwayRadius, so
+jitter around the edge of the fence does not flip the structure back and forth. The structure
+is home while any member is home, and away once every member is away.
+*/
+type Geofence struct {
+	Latitude   float64
+	Longitude  float64
+	HomeRadius float64
+	AwayRadius float64
+	ETARadius  float64
+	Structure  *Structure
+	state      AwayMode
+	members    map[string]*geofenceMember
+	trips      map[string]*Trip
+}
+
+// geofenceMember is the last known position of one member
+type geofenceMember struct {
+	location    Location
+	distance    float64
+	home        bool
+	approaching bool
+}
+
+// geofenceSnapshot is the state of a geofence before an update, gone back to if applying the update fails
+type geofenceSnapshot struct {
+	state   AwayMode
+	members map[string]geofenceMember
+}
+
+/*
+NewGeofence creates a Geofence around the structure at the given coordinates, using the default radii
+
+	fence := structure.NewGeofence(37.4220, -122.0841)
+	fence.Track(nest.Location{MemberID: "jason", Latitude: 37.4275, Longitude: -122.1697, Time: time.Now()})
+*/
+func (s *Structure) NewGeofence(latitude float64, longitude float64) *Geofence {
+	return &Geofence{
+		Latitude:   latitude,
+		Longitude:  longitude,
+		HomeRadius: DefaultHomeRadius,
+		AwayRadius: DefaultAwayRadius,
+		ETARadius:  DefaultETARadius,
+		Structure:  s,
+		members:    make(map[string]*geofenceMember),
+		trips:      make(map[string]*Trip),
+	}
+}
+
+/*
+Track updates the geofence with a location and applies any resulting actions to the structure.
+If applying them fails the location is forgotten, so the next one calls for the actions again.
+*/
+func (g *Geofence) Track(location Location) ([]*GeofenceAction, *APIError) {
+	snapshot := g.snapshot()
+	actions := g.Update(location)
+	apiErr := g.Apply(actions)
+	if apiErr != nil {
+		g.restore(snapshot)
+	}
+	return actions, apiErr
+}
+
+// Update records a location and returns the actions it calls for, without contacting the Nest API.
+// A location older than the member's last one is ignored.
+func (g *Geofence) Update(location Location) []*GeofenceAction {
+	distance := distance(g.Latitude, g.Longitude, location.Latitude, location.Longitude)
+	member, known := g.members[location.MemberID]
+	if known && location.Time.Before(member.location.Time) {
+		return nil
+	}
+	if !known {
+		member = &geofenceMember{home: distance <= g.AwayRadius}
+		g.members[location.MemberID] = member
+	}
+	previous := member.location
+	previousDistance := member.distance
+	member.location = location
+	member.distance = distance
+	if distance <= g.HomeRadius {
+		member.home = true
+	} else if distance > g.AwayRadius {
+		member.home = false
+	}
+
+	var actions []*GeofenceAction
+	if g.anyHome() {
+		for _, m := range g.members {
+			m.approaching = false
+		}
+		if g.state != Home {
+			g.state = Home
+			actions = append(actions, &GeofenceAction{Type: GeofenceSetHome, MemberID: location.MemberID, Time: location.Time})
+		}
+		return actions
+	}
+	if g.state != Away {
+		g.state = Away
+		actions = append(actions, &GeofenceAction{Type: GeofenceSetAway, MemberID: location.MemberID, Time: location.Time})
+	}
+	speed := 0.0
+	if elapsed := location.Time.Sub(previous.Time).Seconds(); known && elapsed > 0 {
+		speed = (previousDistance - distance) / elapsed
+	}
+	if distance <= g.ETARadius && speed >= minApproachSpeed {
+		arrival := maxETA
+		if remaining := (distance - g.HomeRadius) / speed; remaining < maxETA.Seconds() {
+			arrival = time.Duration(remaining * float64(time.Second))
+		}
+		member.approaching = true
+		actions = append(actions, &GeofenceAction{
+			Type:     GeofenceSetETA,
+			MemberID: location.MemberID,
+			Arrival:  location.Time.Add(arrival),
+			Time:     location.Time,
+		})
+	} else if member.approaching && location.Time.After(previous.Time) {
+		member.approaching = false
+		actions = append(actions, &GeofenceAction{Type: GeofenceCancelETA, MemberID: location.MemberID, Time: location.Time})
+	}
+	return actions
+}
+
+/*
+Apply makes the changes called for by actions on the structure. ETAs are sent as one trip per member,
+which is cancelled if they stop or turn back, and all trips are cancelled when someone arrives home.
+
+	apiErr := fence.Apply(fence.Update(location))
+*/
+func (g *Geofence) Apply(actions []*GeofenceAction) *APIError {
+	for _, action := range actions {
+		var apiErr *APIError
+		switch action.Type {
+		case GeofenceSetHome:
+			apiErr = g.cancelTrips()
+			if apiErr == nil {
+				apiErr = g.Structure.SetAway(Home)
+			}
+		case GeofenceSetAway:
+			apiErr = g.Structure.SetAway(Away)
+		case GeofenceSetETA:
+			trip, ok := g.trips[action.MemberID]
+			if !ok {
+				trip = g.Structure.NewTrip(action.MemberID + "-" + strconv.FormatInt(action.Time.Unix(), 10))
+				g.trips[action.MemberID] = trip
+			}
+			apiErr = trip.Arriving(action.Arrival)
+		case GeofenceCancelETA:
+			if trip, ok := g.trips[action.MemberID]; ok {
+				apiErr = trip.Cancel()
+				if apiErr == nil {
+					delete(g.trips, action.MemberID)
+				}
+			}
+		}
+		if apiErr != nil {
+			return apiErr
+		}
+	}
+	return nil
+}
+
+// snapshot copies the state Update changes
+func (g *Geofence) snapshot() *geofenceSnapshot {
+	snapshot := &geofenceSnapshot{state: g.state, members: make(map[string]geofenceMember)}
+	for id, member := range g.members {
+		snapshot.members[id] = *member
+	}
+	return snapshot
+}
+
+// restore goes back to the state in the snapshot
+func (g *Geofence) restore(snapshot *geofenceSnapshot) {
+	g.state = snapshot.state
+	g.members = make(map[string]*geofenceMember)
+	for id, member := range snapshot.members {
+		member := member
+		g.members[id] = &member
+	}
+}
+
+// anyHome returns true if at least one member is home
+func (g *Geofence) anyHome() bool {
+	for _, member := range g.members {
+		if member.home {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelTrips cancels the trips of every member, in member order
+func (g *Geofence) cancelTrips() *APIError {
+	ids := make([]string, 0, len(g.trips))
+	for id := range g.trips {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		apiErr := g.trips[id].Cancel()
+		if apiErr != nil {
+			return apiErr
+		}
+		delete(g.trips, id)
+	}
+	return nil
+}
+
+// distance returns the great-circle distance in meters between two coordinates
+func distance(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,177 @@
+package nest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	homeLatitude  = 37.4220
+	homeLongitude = -122.0841
+)
+
+// at returns a location for the member the given number of degrees north of home
+func at(member string, north float64, when time.Time) Location {
+	return Location{MemberID: member, Latitude: homeLatitude + north, Longitude: homeLongitude, Time: when}
+}
+
+func actionTypes(actions []*GeofenceAction) []GeofenceActionType {
+	types := []GeofenceActionType{}
+	for _, action := range actions {
+		types = append(types, action.Type)
+	}
+	return types
+}
+
+func TestGeofence(t *testing.T) {
+	start := time.Date(2016, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	Convey("When measuring distances", t, func() {
+		So(distance(homeLatitude, homeLongitude, homeLatitude+0.01, homeLongitude), ShouldAlmostEqual, 1112, 1)
+		So(distance(homeLatitude, homeLongitude, homeLatitude, homeLongitude), ShouldEqual, 0)
+	})
+
+	Convey("When one member leaves and returns", t, func() {
+		fence := (&Structure{}).NewGeofence(homeLatitude, homeLongitude)
+		So(actionTypes(fence.Update(at("jason", 0, start))), ShouldResemble, []GeofenceActionType{GeofenceSetHome})
+
+		Convey("Jitter inside the away radius should not set away", func() {
+			So(fence.Update(at("jason", 0.0018, start.Add(time.Minute))), ShouldBeEmpty)
+			So(fence.Update(at("jason", 0.0005, start.Add(2*time.Minute))), ShouldBeEmpty)
+		})
+		Convey("Leaving the away radius should set away once", func() {
+			So(actionTypes(fence.Update(at("jason", 0.003, start.Add(time.Minute)))), ShouldResemble, []GeofenceActionType{GeofenceSetAway})
+			So(fence.Update(at("jason", 0.05, start.Add(5*time.Minute))), ShouldBeEmpty)
+
+			Convey("Coming back into the away radius should not set home", func() {
+				fence.Update(at("jason", 0.003, start.Add(10*time.Minute)))
+				So(actionTypes(fence.Update(at("jason", 0.0018, start.Add(11*time.Minute)))), ShouldNotContain, GeofenceSetHome)
+			})
+			Convey("Approaching should send an ETA from the member's speed", func() {
+				actions := fence.Update(at("jason", 0.04, start.Add(6*time.Minute)))
+				So(actionTypes(actions), ShouldResemble, []GeofenceActionType{GeofenceSetETA})
+				So(actions[0].MemberID, ShouldEqual, "jason")
+				speed := distance(homeLatitude, homeLongitude, homeLatitude+0.01, homeLongitude) / 60
+				remaining := (distance(homeLatitude, homeLongitude, homeLatitude+0.04, homeLongitude) - DefaultHomeRadius) / speed
+				So(actions[0].Arrival.Sub(start.Add(6*time.Minute)).Seconds(), ShouldAlmostEqual, remaining, 1)
+			})
+			Convey("Moving further away should not send an ETA", func() {
+				So(fence.Update(at("jason", 0.06, start.Add(6*time.Minute))), ShouldBeEmpty)
+			})
+			Convey("Creeping towards home should be taken as standing still", func() {
+				So(fence.Update(at("jason", 0.0499999, start.Add(6*time.Minute))), ShouldBeEmpty)
+			})
+			Convey("Turning back or stopping after approaching should cancel the ETA", func() {
+				fence.Update(at("jason", 0.04, start.Add(6*time.Minute)))
+				So(actionTypes(fence.Update(at("jason", 0.045, start.Add(7*time.Minute)))), ShouldResemble, []GeofenceActionType{GeofenceCancelETA})
+				So(fence.Update(at("jason", 0.05, start.Add(8*time.Minute))), ShouldBeEmpty)
+				fence.Update(at("jason", 0.04, start.Add(9*time.Minute)))
+				So(actionTypes(fence.Update(at("jason", 0.04, start.Add(10*time.Minute)))), ShouldResemble, []GeofenceActionType{GeofenceCancelETA})
+			})
+			Convey("A slow approach from far away should put the arrival at most a day ahead", func() {
+				fence.ETARadius = 100000
+				fence.Update(at("jason", 0.8, start.Add(6*time.Minute)))
+				actions := fence.Update(at("jason", 0.7999, start.Add(6*time.Minute+15*time.Second)))
+				So(actionTypes(actions), ShouldResemble, []GeofenceActionType{GeofenceSetETA})
+				So(actions[0].Arrival, ShouldEqual, start.Add(6*time.Minute+15*time.Second).Add(24*time.Hour))
+			})
+			Convey("A location older than the last one should be ignored", func() {
+				So(fence.Update(at("jason", 0.0001, start.Add(4*time.Minute))), ShouldBeEmpty)
+				So(actionTypes(fence.Update(at("jason", 0.04, start.Add(6*time.Minute)))), ShouldResemble, []GeofenceActionType{GeofenceSetETA})
+			})
+			Convey("Arriving inside the home radius should set home", func() {
+				So(actionTypes(fence.Update(at("jason", 0.0001, start.Add(30*time.Minute)))), ShouldResemble, []GeofenceActionType{GeofenceSetHome})
+			})
+		})
+	})
+
+	Convey("When several members share a structure", t, func() {
+		fence := (&Structure{}).NewGeofence(homeLatitude, homeLongitude)
+		fence.Update(at("jason", 0, start))
+		fence.Update(at("anna", 0, start))
+
+		Convey("It should stay home until the last member leaves", func() {
+			So(fence.Update(at("jason", 0.05, start.Add(time.Minute))), ShouldBeEmpty)
+			So(actionTypes(fence.Update(at("anna", 0.05, start.Add(2*time.Minute)))), ShouldResemble, []GeofenceActionType{GeofenceSetAway})
+			So(actionTypes(fence.Update(at("anna", 0, start.Add(30*time.Minute)))), ShouldResemble, []GeofenceActionType{GeofenceSetHome})
+		})
+	})
+}
+
+func TestGeofenceTrack(t *testing.T) {
+	client := New(ClientID, State, ClientSecret, AuthorizationCode)
+	client.Token = Token
+	client.APIURL = ts.URL
+	structures, _ := client.Structures()
+	structure := structures["h68sn..."]
+
+	Convey("When tracking locations against the Nest API", t, func() {
+		now := time.Now()
+		fence := structure.NewGeofence(homeLatitude, homeLongitude)
+		_, err := fence.Track(at("jason", 0.05, now))
+		So(err, ShouldBeNil)
+		So(structure.Away, ShouldEqual, Away)
+
+		actions, err := fence.Track(at("jason", 0.04, now.Add(time.Minute)))
+		So(err, ShouldBeNil)
+		So(actionTypes(actions), ShouldResemble, []GeofenceActionType{GeofenceSetETA})
+		So(structure.ETA.TripID, ShouldStartWith, "jason-")
+
+		_, err = fence.Track(at("jason", 0, now.Add(10*time.Minute)))
+		So(err, ShouldBeNil)
+		So(structure.Away, ShouldEqual, Home)
+		So(structure.ETA, ShouldBeNil)
+	})
+
+	Convey("When a member turns back the trip should be cancelled", t, func() {
+		now := time.Now()
+		fence := structure.NewGeofence(homeLatitude, homeLongitude)
+		fence.Track(at("jason", 0.05, now))
+		_, err := fence.Track(at("jason", 0.04, now.Add(time.Minute)))
+		So(err, ShouldBeNil)
+		So(structure.ETA, ShouldNotBeNil)
+
+		actions, err := fence.Track(at("jason", 0.05, now.Add(2*time.Minute)))
+		So(err, ShouldBeNil)
+		So(actionTypes(actions), ShouldResemble, []GeofenceActionType{GeofenceCancelETA})
+		So(structure.ETA, ShouldBeNil)
+		So(fence.trips, ShouldBeEmpty)
+	})
+}
+
+func TestGeofenceTrackFailure(t *testing.T) {
+	Convey("When the Nest API fails to set away", t, func() {
+		var failing int32 = 1
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if atomic.LoadInt32(&failing) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"error":"service_unavailable"}`))
+				return
+			}
+			w.Write([]byte(`{"away":"away"}`))
+		}))
+		defer server.Close()
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.RedirectURL = server.URL
+		structure := &Structure{StructureID: "s1", Away: Home, Client: client}
+		fence := structure.NewGeofence(homeLatitude, homeLongitude)
+		now := time.Now()
+		_, err := fence.Track(at("jason", 0.05, now))
+		So(err.Description, ShouldEqual, "service_unavailable")
+		So(structure.Away, ShouldEqual, Home)
+
+		Convey("The next location should set away again", func() {
+			atomic.StoreInt32(&failing, 0)
+			actions, err := fence.Track(at("jason", 0.05, now.Add(time.Minute)))
+			So(err, ShouldBeNil)
+			So(actionTypes(actions), ShouldResemble, []GeofenceActionType{GeofenceSetAway})
+			So(structure.Away, ShouldEqual, Away)
+		})
+	})
+}
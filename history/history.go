@@ -0,0 +1,396 @@
+/*
+Package history records how Nest devices and structures change over time. A Recorder flattens
+every device and structure into fields named as in the Nest API, such as ambient_temperature_f
+or hvac_state, and writes a Sample each time a field changes to a Sink.
+
+	file, _ := os.Create("history.csv")
+	sink := history.NewCSVSink(file)
+	recorder := history.NewRecorder(client, sink)
+	recorder.Start()
+	...
+	err := recorder.Stop()
+*/
+package history
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsgoecke/nest"
+)
+
+const (
+	// ThermostatKind is the Kind of samples from thermostats
+	ThermostatKind = "thermostat"
+	// SmokeCoAlarmKind is the Kind of samples from smoke and CO alarms
+	SmokeCoAlarmKind = "smoke_co_alarm"
+	// CameraKind is the Kind of samples from cameras
+	CameraKind = "camera"
+	// StructureKind is the Kind of samples from structures
+	StructureKind = "structure"
+	// RecorderKind is the Kind of the samples a Recorder writes about itself, which have no ID
+	RecorderKind = "recorder"
+	// RecordingField is the field of the RecorderKind samples, true when a Recorder starts and false when it stops
+	RecordingField = "recording"
+	// DefaultBatchSize is how many samples are held before they are written to the sink
+	DefaultBatchSize = 100
+	// DefaultFlushInterval is how often held samples are written and the sink flushed while recording streams
+	DefaultFlushInterval = time.Minute
+	// DefaultRetryDelay is how long to wait before reconnecting a stream that failed or ended
+	DefaultRetryDelay = 5 * time.Second
+)
+
+// Sample is the value of one field of a device or structure from the time it changed
+type Sample struct {
+	Time  time.Time   `json:"time"`
+	Kind  string      `json:"kind"`
+	ID    string      `json:"id"`
+	Field string      `json:"field"`
+	Value interface{} `json:"value"`
+}
+
+// Recorder turns device and structure updates into samples of the fields that changed
+type Recorder struct {
+	Client        *nest.Client
+	Sink          Sink
+	Fields        []string
+	BatchSize     int
+	FlushInterval time.Duration
+	RetryDelay    time.Duration
+	mu            sync.Mutex
+	last          map[string]interface{}
+	batch         []*Sample
+	err           error
+	cancel        context.CancelFunc
+	stopped       chan struct{}
+}
+
+/*
+NewRecorder creates a Recorder writing samples of the client's devices and structures to the sink.
+Set Fields to record only some fields.
+
+	recorder := history.NewRecorder(client, sink)
+	recorder.Fields = []string{"ambient_temperature_f", "target_temperature_f", "hvac_state", "away"}
+*/
+func NewRecorder(client *nest.Client, sink Sink) *Recorder {
+	return &Recorder{
+		Client:        client,
+		Sink:          sink,
+		BatchSize:     DefaultBatchSize,
+		FlushInterval: DefaultFlushInterval,
+		RetryDelay:    DefaultRetryDelay,
+		last:          make(map[string]interface{}),
+	}
+}
+
+/*
+Start records the current devices and structures, then records their streams in the background
+until Stop is called, flushing every FlushInterval. A stream that fails, is refused or ends is
+reconnected after RetryDelay, waiting twice as long for each failure in a row. A RecordingField
+sample marks when recording started, and another when it stopped, so readers of the history know
+values held only until then.
+
+	err := recorder.Start()
+*/
+func (r *Recorder) Start() *nest.APIError {
+	devices, apiErr := r.Client.Devices()
+	if apiErr != nil {
+		return apiErr
+	}
+	structures, apiErr := r.Client.Structures()
+	if apiErr != nil {
+		return apiErr
+	}
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.mu.Unlock()
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.stopped = make(chan struct{})
+	stopped := r.stopped
+	r.mu.Unlock()
+
+	stop := ctx.Done()
+	r.record(stop, func(now time.Time) error { return r.mark(true, now) })
+	r.record(stop, func(now time.Time) error { return r.RecordDevices(devices, now) })
+	r.record(stop, func(now time.Time) error { return r.RecordStructures(structures, now) })
+	running := &sync.WaitGroup{}
+	running.Add(3)
+	go func() {
+		defer running.Done()
+		r.Client.WatchDevices(ctx, r.RetryDelay, func(devices *nest.Devices, err error) {
+			if err == nil {
+				r.record(stop, func(now time.Time) error { return r.RecordDevices(devices, now) })
+			}
+		})
+	}()
+	go func() {
+		defer running.Done()
+		r.Client.WatchStructures(ctx, r.RetryDelay, func(structures map[string]*nest.Structure, err error) {
+			if err == nil {
+				r.record(stop, func(now time.Time) error { return r.RecordStructures(structures, now) })
+			}
+		})
+	}()
+	go func() {
+		defer running.Done()
+		r.flushEvery(stop)
+	}()
+	go func() {
+		running.Wait()
+		close(stopped)
+	}()
+	return nil
+}
+
+/*
+Stop closes the streams, marks that recording stopped, writes the samples held and flushes the
+sink, returning the first error the sink returned while recording. Every field is sampled again
+if the Recorder is started again.
+
+	err := recorder.Stop()
+*/
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	cancel, stopped := r.cancel, r.stopped
+	r.cancel = nil
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-stopped
+		r.record(nil, func(now time.Time) error { return r.mark(false, now) })
+	}
+	err := r.Flush()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return err
+}
+
+// record runs a stream update unless recording was stopped, keeping the first error
+func (r *Recorder) record(stop <-chan struct{}, update func(now time.Time) error) {
+	select {
+	case <-stop:
+		return
+	default:
+	}
+	err := update(time.Now())
+	if err != nil {
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+	}
+}
+
+// mark samples whether the recorder is recording, forgetting the last values seen when it stops
+func (r *Recorder) mark(recording bool, now time.Time) error {
+	if !recording {
+		r.mu.Lock()
+		r.last = make(map[string]interface{})
+		r.mu.Unlock()
+	}
+	return r.add([]*Sample{{Time: now, Kind: RecorderKind, Field: RecordingField, Value: recording}})
+}
+
+// flushEvery flushes every FlushInterval until stop is closed
+func (r *Recorder) flushEvery(stop <-chan struct{}) {
+	ticker := time.NewTicker(r.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.record(stop, func(time.Time) error { return r.Flush() })
+		}
+	}
+}
+
+/*
+RecordDevices samples the fields of every device that changed since the last update
+
+	err := recorder.RecordDevices(devices, time.Now())
+*/
+func (r *Recorder) RecordDevices(devices *nest.Devices, now time.Time) error {
+	samples := []*Sample{}
+	for id, thermostat := range devices.Thermostats {
+		samples = append(samples, r.changes(now, ThermostatKind, id, thermostat)...)
+	}
+	for id, alarm := range devices.SmokeCoAlarms {
+		samples = append(samples, r.changes(now, SmokeCoAlarmKind, id, alarm)...)
+	}
+	for id, camera := range devices.Cameras {
+		samples = append(samples, r.changes(now, CameraKind, id, camera)...)
+	}
+	return r.add(samples)
+}
+
+/*
+RecordStructures samples the fields of every structure that changed since the last update
+
+	err := recorder.RecordStructures(structures, time.Now())
+*/
+func (r *Recorder) RecordStructures(structures map[string]*nest.Structure, now time.Time) error {
+	samples := []*Sample{}
+	for id, structure := range structures {
+		samples = append(samples, r.changes(now, StructureKind, id, structure)...)
+	}
+	return r.add(samples)
+}
+
+// Flush writes the samples held to the sink and flushes it
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.write()
+	if err != nil {
+		return err
+	}
+	return r.Sink.Flush()
+}
+
+// add holds the samples, writing them to the sink once there are BatchSize of them
+func (r *Recorder) add(samples []*Sample) error {
+	sortSamples(samples)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batch = append(r.batch, samples...)
+	if len(r.batch) < r.BatchSize {
+		return nil
+	}
+	return r.write()
+}
+
+// write writes the samples held to the sink
+func (r *Recorder) write() error {
+	if len(r.batch) == 0 {
+		return nil
+	}
+	err := r.Sink.Write(r.batch)
+	if err != nil {
+		return err
+	}
+	r.batch = nil
+	return nil
+}
+
+// changes returns samples of the fields of the object that differ from the last ones seen
+func (r *Recorder) changes(now time.Time, kind string, id string, object interface{}) []*Sample {
+	fields := Flatten(object)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	samples := []*Sample{}
+	for field, value := range fields {
+		if !r.records(field) {
+			continue
+		}
+		key := kind + "/" + id + "/" + field
+		if last, ok := r.last[key]; ok && last == value {
+			continue
+		}
+		r.last[key] = value
+		samples = append(samples, &Sample{Time: now, Kind: kind, ID: id, Field: field, Value: value})
+	}
+	return samples
+}
+
+// records returns whether the field is recorded
+func (r *Recorder) records(field string) bool {
+	if len(r.Fields) == 0 {
+		return true
+	}
+	for _, f := range r.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Flatten returns the fields of a device or structure holding a number, string, bool or time, named
+as in the Nest API. Numbers are float64 and times are RFC 3339 strings. Fields holding false, zero
+or an empty string are included, so a change to one is seen.
+
+	fields := history.Flatten(thermostat)
+	fmt.Println(fields["ambient_temperature_f"])
+*/
+func Flatten(object interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	value := reflect.ValueOf(object)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return fields
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fields
+	}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if flattened, ok := flattenValue(value.Field(i)); ok {
+			fields[name] = flattened
+		}
+	}
+	return fields
+}
+
+// flattenValue converts a number, string, bool or time to the float64, string or bool it is sampled as
+func flattenValue(value reflect.Value) (interface{}, bool) {
+	if t, ok := value.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano), true
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		return value.Bool(), true
+	case reflect.String:
+		return value.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32:
+		// Going through the shortest text keeps 20.1 from becoming 20.100000381469727, as the API would send it
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(value.Float(), 'g', -1, 32), 64)
+		return f, true
+	case reflect.Float64:
+		return value.Float(), true
+	}
+	return nil, false
+}
+
+// sortSamples orders samples by time, kind, ID and field so output is stable
+func sortSamples(samples []*Sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i], samples[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Field < b.Field
+	})
+}
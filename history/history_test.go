@@ -0,0 +1,209 @@
+package history
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsgoecke/nest"
+	"github.com/jsgoecke/nest/nesttest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// memorySink keeps the samples written to it
+type memorySink struct {
+	samples []*Sample
+	writes  int
+	flushes int
+	err     error
+}
+
+func (s *memorySink) Write(samples []*Sample) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.writes++
+	s.samples = append(s.samples, samples...)
+	return nil
+}
+
+func (s *memorySink) Flush() error {
+	s.flushes++
+	return s.err
+}
+
+// fields lists the samples as kind/id/field=value
+func fields(samples []*Sample) []string {
+	list := []string{}
+	for _, sample := range samples {
+		list = append(list, sample.Kind+"/"+sample.ID+"/"+sample.Field+"="+formatValue(sample.Value))
+	}
+	return list
+}
+
+func TestRecorder(t *testing.T) {
+	Convey("When recording updates", t, func() {
+		sink := &memorySink{}
+		recorder := NewRecorder(nil, sink)
+		recorder.Fields = []string{"ambient_temperature_f", "hvac_state", "is_online", "away"}
+		start := time.Unix(1500000000, 0)
+		thermostat := &nest.Thermostat{DeviceID: "t1", AmbientTemperatureF: 67, HvacState: nest.Heating, IsOnline: true}
+		devices := &nest.Devices{Thermostats: map[string]*nest.Thermostat{"t1": thermostat}}
+		So(recorder.RecordDevices(devices, start), ShouldBeNil)
+
+		Convey("Samples should be held until flushed", func() {
+			So(sink.samples, ShouldBeEmpty)
+			So(recorder.Flush(), ShouldBeNil)
+			So(sink.flushes, ShouldEqual, 1)
+			So(fields(sink.samples), ShouldResemble, []string{
+				"thermostat/t1/ambient_temperature_f=67",
+				"thermostat/t1/hvac_state=heating",
+				"thermostat/t1/is_online=true",
+			})
+			So(sink.samples[0].Time, ShouldEqual, start)
+		})
+		Convey("Only fields that changed should be sampled again", func() {
+			thermostat.AmbientTemperatureF = 68
+			So(recorder.RecordDevices(devices, start.Add(time.Minute)), ShouldBeNil)
+			So(recorder.RecordDevices(devices, start.Add(2*time.Minute)), ShouldBeNil)
+			recorder.Flush()
+			So(fields(sink.samples[3:]), ShouldResemble, []string{"thermostat/t1/ambient_temperature_f=68"})
+			So(sink.samples[3].Time, ShouldEqual, start.Add(time.Minute))
+		})
+		Convey("Fields turning false or zero should be sampled", func() {
+			thermostat.IsOnline = false
+			thermostat.AmbientTemperatureF = 0
+			So(recorder.RecordDevices(devices, start.Add(time.Minute)), ShouldBeNil)
+			recorder.Flush()
+			So(fields(sink.samples[3:]), ShouldResemble, []string{
+				"thermostat/t1/ambient_temperature_f=0",
+				"thermostat/t1/is_online=false",
+			})
+		})
+		Convey("Structures should be sampled", func() {
+			structures := map[string]*nest.Structure{"s1": {StructureID: "s1", Away: nest.Away}}
+			So(recorder.RecordStructures(structures, start), ShouldBeNil)
+			recorder.Flush()
+			So(fields(sink.samples), ShouldContain, "structure/s1/away=away")
+		})
+		Convey("Samples should be written once a batch is full", func() {
+			recorder.BatchSize = 4
+			thermostat.HvacState = nest.Idle
+			So(recorder.RecordDevices(devices, start.Add(time.Minute)), ShouldBeNil)
+			So(sink.writes, ShouldEqual, 1)
+			So(len(sink.samples), ShouldEqual, 4)
+			So(sink.flushes, ShouldEqual, 0)
+		})
+		Convey("Samples should be kept when the sink fails", func() {
+			sink.err = errors.New("disk full")
+			So(recorder.Flush(), ShouldEqual, sink.err)
+			sink.err = nil
+			So(recorder.Flush(), ShouldBeNil)
+			So(len(sink.samples), ShouldEqual, 3)
+		})
+	})
+}
+
+func TestFlatten(t *testing.T) {
+	Convey("Flatten should keep the numbers, strings and bools named as in the API", t, func() {
+		fields := Flatten(&nest.Structure{StructureID: "s1", Away: nest.Home, Thermostats: []string{"t1"}})
+		So(fields["structure_id"], ShouldEqual, "s1")
+		So(fields["away"], ShouldEqual, "home")
+		So(fields, ShouldNotContainKey, "thermostats")
+		fields = Flatten(&nest.Thermostat{AmbientTemperatureC: 20.5, CanCool: true})
+		So(fields["ambient_temperature_c"], ShouldEqual, 20.5)
+		So(fields["can_cool"], ShouldEqual, true)
+	})
+	Convey("Flatten should keep fields holding false, zero or nothing", t, func() {
+		fields := Flatten(&nest.Thermostat{TargetTemperatureC: 20.1})
+		So(fields["target_temperature_c"], ShouldEqual, 20.1)
+		So(fields["fan_timer_active"], ShouldEqual, false)
+		So(fields["ambient_temperature_f"], ShouldEqual, 0)
+		So(fields["label"], ShouldEqual, "")
+		So(fields, ShouldNotContainKey, "Client")
+	})
+}
+
+// waitUntil polls the condition for a few seconds, reporting whether it became true
+func waitUntil(condition func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+// countingTransport counts the requests sent through it
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRecorderStream(t *testing.T) {
+	Convey("When recording from the streams", t, func() {
+		server := nesttest.NewServer()
+		defer server.Close()
+		server.AddThermostat(&nest.Thermostat{DeviceID: "t1", StructureID: "s1", AmbientTemperatureF: 67, HvacState: nest.Idle})
+		server.AddStructure(&nest.Structure{StructureID: "s1", Away: nest.Home, Thermostats: []string{"t1"}})
+		buffer := &bytes.Buffer{}
+		transport := &countingTransport{}
+		client := server.Client()
+		client.HTTPClient = &http.Client{Transport: transport}
+		recorder := NewRecorder(client, NewJSONSink(buffer))
+		recorder.Fields = []string{"ambient_temperature_f", "away"}
+		recorder.FlushInterval = 10 * time.Millisecond
+		recorder.RetryDelay = 20 * time.Millisecond
+		So(recorder.Start(), ShouldBeNil)
+
+		Convey("Changes should be written until stopped", func() {
+			server.Set("/devices/thermostats/t1/ambient_temperature_f", 69)
+			server.Set("/structures/s1/away", "away")
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				recorder.mu.Lock()
+				done := strings.Contains(buffer.String(), `"value":69`) && strings.Contains(buffer.String(), `"value":"away"`)
+				recorder.mu.Unlock()
+				if done {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(recorder.Stop(), ShouldBeNil)
+			lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+			So(len(lines), ShouldEqual, 6)
+			So(lines[0], ShouldContainSubstring, `"kind":"recorder","id":"","field":"recording","value":true}`)
+			So(lines[1], ShouldContainSubstring, `"kind":"thermostat","id":"t1","field":"ambient_temperature_f","value":67}`)
+			So(lines[5], ShouldContainSubstring, `"kind":"recorder","id":"","field":"recording","value":false}`)
+			So(buffer.String(), ShouldContainSubstring, `"field":"ambient_temperature_f","value":69}`)
+			So(buffer.String(), ShouldContainSubstring, `"kind":"structure","id":"s1","field":"away","value":"away"}`)
+
+			server.Set("/devices/thermostats/t1/ambient_temperature_f", 70)
+			time.Sleep(50 * time.Millisecond)
+			So(buffer.String(), ShouldNotContainSubstring, `"value":70`)
+		})
+		Convey("Stopping should close the streams", func() {
+			So(waitUntil(func() bool { return server.OpenStreams() == 2 }), ShouldBeTrue)
+			So(recorder.Stop(), ShouldBeNil)
+			So(waitUntil(func() bool { return server.OpenStreams() == 0 }), ShouldBeTrue)
+		})
+		Convey("A revoked token should be retried with a growing delay", func() {
+			defer recorder.Stop()
+			So(waitUntil(func() bool { return server.OpenStreams() == 2 }), ShouldBeTrue)
+			before := atomic.LoadInt32(&transport.requests)
+			server.RevokeAuth()
+			time.Sleep(500 * time.Millisecond)
+			So(atomic.LoadInt32(&transport.requests)-before, ShouldBeBetweenOrEqual, 2, 16)
+		})
+	})
+}
@@ -0,0 +1,161 @@
+package history
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sink is where a Recorder writes samples
+type Sink interface {
+	// Write writes a batch of samples, in time order
+	Write(samples []*Sample) error
+	// Flush makes sure every sample written so far is stored
+	Flush() error
+}
+
+// CSVSink writes samples as CSV with the columns time, kind, id, field and value
+type CSVSink struct {
+	writer *csv.Writer
+	header bool
+}
+
+// JSONSink writes samples as JSON lines
+type JSONSink struct {
+	writer *bufio.Writer
+}
+
+// InfluxSink writes samples in the InfluxDB line protocol, measured by kind and tagged by id
+type InfluxSink struct {
+	writer *bufio.Writer
+}
+
+/*
+NewCSVSink creates a CSVSink writing to w, starting with a header row
+
+	file, _ := os.Create("history.csv")
+	sink := history.NewCSVSink(file)
+*/
+func NewCSVSink(w io.Writer) *CSVSink {
+	return &CSVSink{writer: csv.NewWriter(w)}
+}
+
+// Write writes a row for each sample, writing the header first if this is the first write
+func (s *CSVSink) Write(samples []*Sample) error {
+	if !s.header {
+		err := s.writer.Write([]string{"time", "kind", "id", "field", "value"})
+		if err != nil {
+			return err
+		}
+		s.header = true
+	}
+	for _, sample := range samples {
+		err := s.writer.Write([]string{
+			sample.Time.UTC().Format(time.RFC3339Nano),
+			sample.Kind,
+			sample.ID,
+			sample.Field,
+			formatValue(sample.Value),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered rows
+func (s *CSVSink) Flush() error {
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+/*
+NewJSONSink creates a JSONSink writing to w
+
+	file, _ := os.Create("history.jsonl")
+	sink := history.NewJSONSink(file)
+*/
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{writer: bufio.NewWriter(w)}
+}
+
+// Write writes a JSON object on its own line for each sample
+func (s *JSONSink) Write(samples []*Sample) error {
+	for _, sample := range samples {
+		data, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+		_, err = s.writer.Write(append(data, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered lines
+func (s *JSONSink) Flush() error {
+	return s.writer.Flush()
+}
+
+/*
+NewInfluxSink creates an InfluxSink writing to w
+
+	file, _ := os.Create("history.influx")
+	sink := history.NewInfluxSink(file)
+*/
+func NewInfluxSink(w io.Writer) *InfluxSink {
+	return &InfluxSink{writer: bufio.NewWriter(w)}
+}
+
+// Write writes a line for each sample, such as thermostat,id=t1 ambient_temperature_f=68 1500000000000000000
+func (s *InfluxSink) Write(samples []*Sample) error {
+	for _, sample := range samples {
+		value := ""
+		switch v := sample.Value.(type) {
+		case string:
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+		default:
+			value = formatValue(v)
+		}
+		line := escapeInflux(sample.Kind, ", ") + ",id=" + escapeInflux(sample.ID, ",= ") + " " +
+			escapeInflux(sample.Field, ",= ") + "=" + value + " " + strconv.FormatInt(sample.Time.UnixNano(), 10) + "\n"
+		_, err := s.writer.WriteString(line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered lines
+func (s *InfluxSink) Flush() error {
+	return s.writer.Flush()
+}
+
+// formatValue formats a number, string or bool sample value
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// escapeInflux escapes the characters special to the part of an InfluxDB line
+func escapeInflux(text string, special string) string {
+	for _, c := range special {
+		text = strings.Replace(text, string(c), `\`+string(c), -1)
+	}
+	return text
+}
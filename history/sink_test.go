@@ -0,0 +1,57 @@
+package history
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// samples are written by every sink test
+var samples = []*Sample{
+	{Time: time.Unix(1500000000, 0), Kind: ThermostatKind, ID: "t1", Field: "ambient_temperature_f", Value: 67.5},
+	{Time: time.Unix(1500000060, 0), Kind: ThermostatKind, ID: "t1", Field: "label", Value: `Jim's "Den", upstairs`},
+	{Time: time.Unix(1500000120, 0), Kind: StructureKind, ID: "s 1", Field: "rhr_enrollment", Value: true},
+}
+
+func TestCSVSink(t *testing.T) {
+	Convey("A CSVSink should write a header and a row per sample once flushed", t, func() {
+		buffer := &bytes.Buffer{}
+		sink := NewCSVSink(buffer)
+		So(sink.Write(samples[:1]), ShouldBeNil)
+		So(buffer.String(), ShouldBeEmpty)
+		So(sink.Write(samples[1:]), ShouldBeNil)
+		So(sink.Flush(), ShouldBeNil)
+		So(buffer.String(), ShouldEqual, "time,kind,id,field,value\n"+
+			"2017-07-14T02:40:00Z,thermostat,t1,ambient_temperature_f,67.5\n"+
+			"2017-07-14T02:41:00Z,thermostat,t1,label,\"Jim's \"\"Den\"\", upstairs\"\n"+
+			"2017-07-14T02:42:00Z,structure,s 1,rhr_enrollment,true\n")
+	})
+}
+
+func TestJSONSink(t *testing.T) {
+	Convey("A JSONSink should write a line per sample once flushed", t, func() {
+		buffer := &bytes.Buffer{}
+		sink := NewJSONSink(buffer)
+		So(sink.Write(samples), ShouldBeNil)
+		So(buffer.String(), ShouldBeEmpty)
+		So(sink.Flush(), ShouldBeNil)
+		So(buffer.String(), ShouldStartWith, `{"time":"2017-07-14T`)
+		So(buffer.String(), ShouldContainSubstring, `"kind":"thermostat","id":"t1","field":"ambient_temperature_f","value":67.5}`+"\n")
+		So(buffer.String(), ShouldContainSubstring, `"field":"label","value":"Jim's \"Den\", upstairs"}`+"\n")
+	})
+}
+
+func TestInfluxSink(t *testing.T) {
+	Convey("An InfluxSink should write escaped lines with nanosecond timestamps once flushed", t, func() {
+		buffer := &bytes.Buffer{}
+		sink := NewInfluxSink(buffer)
+		So(sink.Write(samples), ShouldBeNil)
+		So(buffer.String(), ShouldBeEmpty)
+		So(sink.Flush(), ShouldBeNil)
+		So(buffer.String(), ShouldEqual, "thermostat,id=t1 ambient_temperature_f=67.5 1500000000000000000\n"+
+			`thermostat,id=t1 label="Jim's \"Den\", upstairs" 1500000060000000000`+"\n"+
+			`structure,id=s\ 1 rhr_enrollment=true 1500000120000000000`+"\n")
+	})
+}
@@ -0,0 +1,304 @@
+/*
+Package sqlite stores history samples in an embedded SQLite database and answers questions about
+them, such as how long a thermostat was heating on a given day. A Store is a history.Sink, so a
+history.Recorder can write straight to it.
+
+	store, err := sqlite.Open("history.db")
+	recorder := history.NewRecorder(client, store)
+	recorder.Start()
+	...
+	runtime, err := store.HvacRuntime(deviceID, time.Now())
+*/
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jsgoecke/nest"
+	"github.com/jsgoecke/nest/history"
+	// Registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// schema creates the samples table, indexed for looking a field of a device or structure up by time
+const schema = `
+CREATE TABLE IF NOT EXISTS samples (
+	time INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	id TEXT NOT NULL,
+	field TEXT NOT NULL,
+	type TEXT NOT NULL,
+	value
+);
+CREATE INDEX IF NOT EXISTS samples_id_field_time ON samples (id, field, time);
+`
+
+const (
+	numberType = "number"
+	stringType = "string"
+	boolType   = "bool"
+)
+
+// Store is a history.Sink keeping samples in a SQLite database
+type Store struct {
+	DB *sql.DB
+	// Now returns the current time, where runtimes and intervals still going on end
+	Now func() time.Time
+	// MaxGap, when set, is the longest a value is taken to hold after its sample, for when a recorder
+	// stopped without marking it, such as when it crashed. Recorders only sample changes, so it must be
+	// longer than a value is expected to go unchanged.
+	MaxGap time.Duration
+}
+
+// Interval is a span of time a structure spent in an away mode. End is zero if it still is.
+type Interval struct {
+	Start time.Time
+	End   time.Time
+	Mode  nest.AwayMode
+}
+
+/*
+Open opens the SQLite database at path, creating it and its table if needed
+
+	store, err := sqlite.Open("history.db")
+*/
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{DB: db, Now: time.Now}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
+
+// Write stores the samples in one transaction
+func (s *Store) Write(samples []*history.Sample) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	insert, err := tx.Prepare("INSERT INTO samples (time, kind, id, field, type, value) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer insert.Close()
+	for _, sample := range samples {
+		valueType := stringType
+		switch sample.Value.(type) {
+		case float64:
+			valueType = numberType
+		case bool:
+			valueType = boolType
+		}
+		_, err = insert.Exec(sample.Time.UnixNano(), sample.Kind, sample.ID, sample.Field, valueType, sample.Value)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Flush does nothing, as every write is committed
+func (s *Store) Flush() error {
+	return nil
+}
+
+/*
+History returns the samples of a field of a device or structure from from until to, starting with
+the last sample before from so the value at from is known
+
+	samples, err := store.History(deviceID, "ambient_temperature_f", from, to)
+*/
+func (s *Store) History(id string, field string, from time.Time, to time.Time) ([]*history.Sample, error) {
+	rows, err := s.DB.Query(`
+		SELECT time, kind, id, field, type, value FROM (
+			SELECT * FROM (SELECT * FROM samples WHERE id = ? AND field = ? AND time < ? ORDER BY time DESC, rowid DESC LIMIT 1)
+			UNION ALL
+			SELECT * FROM samples WHERE id = ? AND field = ? AND time >= ? AND time < ?
+		) ORDER BY time`,
+		id, field, from.UnixNano(), id, field, from.UnixNano(), to.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	samples := []*history.Sample{}
+	for rows.Next() {
+		sample := &history.Sample{}
+		var nanoseconds int64
+		var valueType string
+		var value interface{}
+		err = rows.Scan(&nanoseconds, &sample.Kind, &sample.ID, &sample.Field, &valueType, &value)
+		if err != nil {
+			return nil, err
+		}
+		sample.Time = time.Unix(0, nanoseconds)
+		sample.Value = decodeValue(valueType, value)
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
+
+/*
+HvacRuntime returns how long a thermostat spent heating, cooling and running its fan timer on the
+day, in the day's location. Only the durations of the usage are set.
+
+	runtime, err := store.HvacRuntime(deviceID, time.Now())
+	fmt.Println(runtime.Heating)
+*/
+func (s *Store) HvacRuntime(deviceID string, day time.Time) (*nest.RuntimeUsage, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+	runtime := &nest.RuntimeUsage{}
+	states, err := s.durations(deviceID, "hvac_state", from, to)
+	if err != nil {
+		return nil, err
+	}
+	runtime.Heating = states[string(nest.Heating)]
+	runtime.Cooling = states[string(nest.Cooling)]
+	fan, err := s.durations(deviceID, "fan_timer_active", from, to)
+	if err != nil {
+		return nil, err
+	}
+	runtime.Fan = fan["true"]
+	return runtime, nil
+}
+
+/*
+AwayIntervals returns every interval the structure spent away or auto-away, oldest first
+
+	intervals, err := store.AwayIntervals(structureID)
+*/
+func (s *Store) AwayIntervals(structureID string) ([]*Interval, error) {
+	samples, err := s.History(structureID, "away", time.Unix(0, 0), s.Now())
+	if err != nil {
+		return nil, err
+	}
+	intervals := []*Interval{}
+	var current *Interval
+	for _, sample := range samples {
+		mode := nest.AwayMode(valueString(sample.Value))
+		if current != nil && current.Mode == mode {
+			continue
+		}
+		if current != nil {
+			current.End = sample.Time
+			current = nil
+		}
+		if mode == nest.Away || mode == nest.AutoAway {
+			current = &Interval{Start: sample.Time, Mode: mode}
+			intervals = append(intervals, current)
+		}
+	}
+	return intervals, nil
+}
+
+/*
+durations adds up how long a field held each value between from and to, ending at Now if that is earlier.
+A value stops being counted when the recorder stopped, or MaxGap after its sample.
+*/
+func (s *Store) durations(id string, field string, from time.Time, to time.Time) (map[string]time.Duration, error) {
+	samples, err := s.History(id, field, from, to)
+	if err != nil || len(samples) == 0 {
+		return make(map[string]time.Duration), err
+	}
+	if now := s.Now(); now.Before(to) {
+		to = now
+	}
+	stops, err := s.stops(samples[0].Time, to)
+	if err != nil {
+		return nil, err
+	}
+	durations := make(map[string]time.Duration)
+	for i, sample := range samples {
+		start, end := sample.Time, to
+		if i+1 < len(samples) {
+			end = samples[i+1].Time
+		}
+		for _, stop := range stops {
+			if !stop.Before(start) {
+				if stop.Before(end) {
+					end = stop
+				}
+				break
+			}
+		}
+		if s.MaxGap > 0 && end.Sub(start) > s.MaxGap {
+			end = start.Add(s.MaxGap)
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(start) {
+			durations[valueString(sample.Value)] += end.Sub(start)
+		}
+	}
+	return durations, nil
+}
+
+// stops returns when recorders marked that they stopped between from and to, in order
+func (s *Store) stops(from time.Time, to time.Time) ([]time.Time, error) {
+	rows, err := s.DB.Query(`
+		SELECT time FROM samples WHERE kind = ? AND field = ? AND type = ? AND value = 0 AND time >= ? AND time < ?
+		ORDER BY time`,
+		history.RecorderKind, history.RecordingField, boolType, from.UnixNano(), to.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stops := []time.Time{}
+	for rows.Next() {
+		var nanoseconds int64
+		err = rows.Scan(&nanoseconds)
+		if err != nil {
+			return nil, err
+		}
+		stops = append(stops, time.Unix(0, nanoseconds))
+	}
+	return stops, rows.Err()
+}
+
+// decodeValue turns a stored value back into the float64, string or bool it was written as
+func decodeValue(valueType string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case int64:
+		if valueType == boolType {
+			return v != 0
+		}
+		return float64(v)
+	case bool:
+		return v
+	case float64:
+		return v
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	return value
+}
+
+// valueString formats a sample value for comparing
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	return ""
+}
@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jsgoecke/nest"
+	"github.com/jsgoecke/nest/history"
+	"github.com/jsgoecke/nest/nesttest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// day is midnight at the start of the day the tests record
+var day = time.Date(2017, 7, 14, 0, 0, 0, 0, time.UTC)
+
+// at returns the time the hours and minutes into the day
+func at(hours int, minutes int) time.Time {
+	return day.Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute)
+}
+
+// sample creates a thermostat sample
+func sample(t time.Time, field string, value interface{}) *history.Sample {
+	return &history.Sample{Time: t, Kind: history.ThermostatKind, ID: "t1", Field: field, Value: value}
+}
+
+func TestStore(t *testing.T) {
+	Convey("When storing samples", t, func() {
+		dir, _ := ioutil.TempDir("", "nest-history")
+		defer os.RemoveAll(dir)
+		store, err := Open(filepath.Join(dir, "history.db"))
+		So(err, ShouldBeNil)
+		defer store.Close()
+		store.Now = func() time.Time { return at(30, 0) }
+		So(store.Write([]*history.Sample{
+			sample(day.Add(-2*time.Hour), "hvac_state", "heating"),
+			sample(day.Add(-time.Hour), "ambient_temperature_f", 66.0),
+			sample(at(1, 0), "hvac_state", "off"),
+			sample(at(1, 0), "ambient_temperature_f", 68.5),
+			sample(at(6, 0), "hvac_state", "heating"),
+			sample(at(6, 30), "hvac_state", "off"),
+			sample(at(6, 30), "fan_timer_active", true),
+			sample(at(6, 45), "fan_timer_active", false),
+			sample(at(14, 0), "hvac_state", "cooling"),
+			sample(at(14, 0), "label", "Den"),
+		}), ShouldBeNil)
+		So(store.Flush(), ShouldBeNil)
+
+		Convey("History should start with the value in effect", func() {
+			samples, err := store.History("t1", "ambient_temperature_f", at(0, 30), at(2, 0))
+			So(err, ShouldBeNil)
+			So(len(samples), ShouldEqual, 2)
+			So(samples[0].Time.Equal(day.Add(-time.Hour)), ShouldBeTrue)
+			So(samples[0].Value, ShouldEqual, 66.0)
+			So(samples[1].Value, ShouldEqual, 68.5)
+			So(samples[1].Kind, ShouldEqual, history.ThermostatKind)
+
+			samples, _ = store.History("t1", "fan_timer_active", day, at(24, 0))
+			So(samples[0].Value, ShouldEqual, true)
+			So(samples[1].Value, ShouldEqual, false)
+			samples, _ = store.History("t1", "label", day, at(24, 0))
+			So(samples[0].Value, ShouldEqual, "Den")
+			samples, _ = store.History("t2", "label", day, at(24, 0))
+			So(samples, ShouldBeEmpty)
+		})
+		Convey("HvacRuntime should add up the day's heating, cooling and fan time", func() {
+			runtime, err := store.HvacRuntime("t1", at(12, 0))
+			So(err, ShouldBeNil)
+			So(runtime.Heating, ShouldEqual, 90*time.Minute)
+			So(runtime.Cooling, ShouldEqual, 10*time.Hour)
+			So(runtime.Fan, ShouldEqual, 15*time.Minute)
+		})
+		Convey("HvacRuntime should stop at the current time", func() {
+			runtime, _ := store.HvacRuntime("t1", at(24, 0))
+			So(runtime.Cooling, ShouldEqual, 6*time.Hour)
+			store.Now = func() time.Time { return at(15, 0) }
+			runtime, _ = store.HvacRuntime("t1", day)
+			So(runtime.Cooling, ShouldEqual, time.Hour)
+		})
+		Convey("HvacRuntime should stop counting where the recorder stopped", func() {
+			store.Write([]*history.Sample{
+				{Time: at(15, 0), Kind: history.RecorderKind, Field: history.RecordingField, Value: false},
+				{Time: at(20, 0), Kind: history.RecorderKind, Field: history.RecordingField, Value: true},
+				sample(at(20, 0), "hvac_state", "cooling"),
+				sample(at(20, 30), "hvac_state", "off"),
+			})
+			runtime, _ := store.HvacRuntime("t1", day)
+			So(runtime.Cooling, ShouldEqual, 90*time.Minute)
+		})
+		Convey("HvacRuntime should count a value for at most MaxGap", func() {
+			store.MaxGap = 4 * time.Hour
+			runtime, _ := store.HvacRuntime("t1", day)
+			So(runtime.Cooling, ShouldEqual, 4*time.Hour)
+			So(runtime.Heating, ShouldEqual, 90*time.Minute)
+		})
+		Convey("AwayIntervals should list the spans spent away", func() {
+			structure := func(t time.Time, away nest.AwayMode) *history.Sample {
+				return &history.Sample{Time: t, Kind: history.StructureKind, ID: "s1", Field: "away", Value: string(away)}
+			}
+			store.Write([]*history.Sample{
+				structure(at(0, 0), nest.Home),
+				structure(at(8, 0), nest.Away),
+				structure(at(9, 0), nest.AutoAway),
+				structure(at(17, 0), nest.Home),
+				structure(at(23, 0), nest.Away),
+			})
+			intervals, err := store.AwayIntervals("s1")
+			So(err, ShouldBeNil)
+			So(len(intervals), ShouldEqual, 3)
+			So(intervals[0].Mode, ShouldEqual, nest.Away)
+			So(intervals[0].Start.Equal(at(8, 0)), ShouldBeTrue)
+			So(intervals[0].End.Equal(at(9, 0)), ShouldBeTrue)
+			So(intervals[1].Mode, ShouldEqual, nest.AutoAway)
+			So(intervals[1].End.Equal(at(17, 0)), ShouldBeTrue)
+			So(intervals[2].End.IsZero(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestRecorderToStore(t *testing.T) {
+	Convey("A Recorder writing to a Store should give the runtime of what it recorded", t, func() {
+		dir, _ := ioutil.TempDir("", "nest-history")
+		defer os.RemoveAll(dir)
+		store, err := Open(filepath.Join(dir, "history.db"))
+		So(err, ShouldBeNil)
+		defer store.Close()
+		store.Now = func() time.Time { return at(24, 0) }
+		recorder := history.NewRecorder(nil, store)
+		thermostat := &nest.Thermostat{DeviceID: "t1", HvacState: nest.Idle}
+		devices := &nest.Devices{Thermostats: map[string]*nest.Thermostat{"t1": thermostat}}
+		So(recorder.RecordDevices(devices, at(0, 0)), ShouldBeNil)
+		thermostat.HvacState = nest.Heating
+		thermostat.FanTimerActive = true
+		So(recorder.RecordDevices(devices, at(7, 0)), ShouldBeNil)
+		thermostat.HvacState = nest.Idle
+		thermostat.FanTimerActive = false
+		So(recorder.RecordDevices(devices, at(7, 20)), ShouldBeNil)
+		So(recorder.Flush(), ShouldBeNil)
+
+		runtime, err := store.HvacRuntime("t1", day)
+		So(err, ShouldBeNil)
+		So(runtime.Heating, ShouldEqual, 20*time.Minute)
+		So(runtime.Fan, ShouldEqual, 20*time.Minute)
+	})
+}
+
+func TestRecorderStopToStore(t *testing.T) {
+	Convey("A Store should not count time after its Recorder stopped", t, func() {
+		dir, _ := ioutil.TempDir("", "nest-history")
+		defer os.RemoveAll(dir)
+		store, err := Open(filepath.Join(dir, "history.db"))
+		So(err, ShouldBeNil)
+		defer store.Close()
+		server := nesttest.NewServer()
+		defer server.Close()
+		server.AddThermostat(&nest.Thermostat{DeviceID: "t1", HvacMode: nest.Heat, HvacState: nest.Heating})
+		recorder := history.NewRecorder(server.Client(), store)
+		So(recorder.Start(), ShouldBeNil)
+		time.Sleep(50 * time.Millisecond)
+		So(recorder.Stop(), ShouldBeNil)
+
+		now := time.Now()
+		store.Now = func() time.Time { return now.Add(time.Hour) }
+		runtime, err := store.HvacRuntime("t1", now)
+		So(err, ShouldBeNil)
+		So(runtime.Heating, ShouldBeGreaterThan, 0)
+		So(runtime.Heating, ShouldBeLessThan, time.Second)
+	})
+}
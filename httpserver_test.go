@@ -39,6 +39,16 @@ func serveHTTP(t *testing.T) *httptest.Server {
 				w.WriteHeader(200)
 				w.Write(structuresJSON())
 			}
+		case "/structures/h68sn...?auth=" + Token, "/structures/s1234?auth=" + Token:
+			w.WriteHeader(200)
+			w.Write(body)
+		case "/structures/s1234/wheres.json?auth=" + Token:
+			if req.Method != "POST" {
+				w.WriteHeader(405)
+				return
+			}
+			w.WriteHeader(200)
+			w.Write([]byte(`{"where_id":"w5678",` + strings.TrimPrefix(string(body), "{")))
 		case "/devices.json?auth=" + Token:
 			if req.Header.Get("Accept") == "text/event-stream" {
 				f, _ := w.(http.Flusher)
@@ -56,12 +66,20 @@ func serveHTTP(t *testing.T) *httptest.Server {
 		case "/redirected/devices.json?auth=" + Token:
 			w.WriteHeader(200)
 			w.Write(devicesResponseJSON())
+		case "/devices/cameras/c1234?auth=" + Token:
+			w.WriteHeader(200)
+			w.Write(body)
 		case "/devices/thermostats/z1234?auth=" + Token:
 			if strings.Contains(string(body), "fan_timer_active") {
 				w.WriteHeader(200)
 				w.Write(body)
 				return
 			}
+			if strings.Contains(string(body), "label") || strings.Contains(string(body), "fan_timer_duration") || strings.Contains(string(body), "temperature_scale") {
+				w.WriteHeader(200)
+				w.Write(body)
+				return
+			}
 			if strings.Contains(string(body), "hvac_mode") {
 				w.WriteHeader(200)
 				w.Write(body)
@@ -113,13 +131,13 @@ func devicesResponseJSON() []byte {
 		            "locale": "en-US",
 		            "temperature_scale": "F",
 		            "is_using_emergency_heat": false,
-		            "has_fan": false,
+		            "has_fan": true,
 		            "software_version": "4.2.4",
 		            "has_leaf": true,
 		            "device_id": "z1234",
 		            "name": "Bedroom (Main)",
 		            "can_heat": true,
-		            "can_cool": false,
+		            "can_cool": true,
 		            "hvac_mode": "heat",
 		            "target_temperature_c": 10,
 		            "target_temperature_f": 50,
@@ -169,11 +187,31 @@ func devicesResponseJSON() []byte {
 		            "smoke_alarm_state": "ok",
 		            "ui_color_state": "green"
 		        }
+		    },
+		    "cameras": {
+		        "c1234": {
+		            "device_id": "c1234",
+		            "software_version": "4.0",
+		            "structure_id": "s1234",
+		            "where_id": "w1234",
+		            "where_name": "Front Door",
+		            "name": "Front Door",
+		            "name_long": "Front Door Camera",
+		            "is_online": true,
+		            "is_streaming": true,
+		            "activity_zones": [{"name": "Walkway", "id": 244083}],
+		            "last_event": {
+		                "has_motion": true,
+		                "start_time": "2014-08-28T23:00:00.000Z",
+		                "end_time": "2014-08-28T23:00:30.000Z",
+		                "activity_zone_ids": ["244083"]
+		            }
+		        }
 		    }
 		}
 		`)
 }
 
 func streamEvent() []byte {
-	return []byte(`{"path":"/devices","data":{"thermostats":{"z1234":{"locale":"en-US","temperature_scale":"F","is_using_emergency_heat":false,"has_fan":true,"software_version":"4.1","has_leaf":true,"device_id":"z1234","name":"Entryway","can_heat":true,"can_cool":true,"hvac_mode":"heat","target_temperature_c":19.0,"target_temperature_f":67,"target_temperature_high_c":24.0,"target_temperature_high_f":75,"target_temperature_low_c":20.0,"target_temperature_low_f":68,"ambient_temperature_c":21.0,"ambient_temperature_f":70,"away_temperature_high_c":24.0,"away_temperature_high_f":76,"away_temperature_low_c":12.5,"away_temperature_low_f":55,"structure_id":"s1234","fan_timer_active":false,"name_long":"Entryway Thermostat","is_online":true},"z5678":{"locale":"en-US","temperature_scale":"F","is_using_emergency_heat":false,"has_fan":false,"software_version":"4.2.4","has_leaf":true,"device_id":"Zz5678","name":"Bedroom (Master)","can_heat":true,"can_cool":false,"hvac_mode":"heat","target_temperature_c":10.0,"target_temperature_f":50,"target_temperature_high_c":24.0,"target_temperature_high_f":75,"target_temperature_low_c":20.0,"target_temperature_low_f":68,"ambient_temperature_c":20.5,"ambient_temperature_f":70,"away_temperature_high_c":24.0,"away_temperature_high_f":76,"away_temperature_low_c":10.0,"away_temperature_low_f":50,"structure_id":"s1234","fan_timer_active":false,"name_long":"Bedroom Thermostat (Master)","is_online":true,"last_connection":"2014-08-30T16:29:45.165Z"}},"smoke_co_alarms":{"a1234":{"name":"Upstairs Hallway","locale":"en-US","structure_id":"a1234","software_version":"1.0rc12","device_id":"a1234","name_long":"Upstairs Hallway Nest Protect","is_online":true,"last_connection":"2014-08-30T05:35:47.025Z","battery_health":"ok","co_alarm_state":"ok","smoke_alarm_state":"ok","ui_color_state":"green"},"a3455":{"name":"Bedroom","locale":"en-US","structure_id":"s1234","software_version":"1.0.2rc2","device_id":"a3455","name_long":"Bedroom Nest Protect","is_online":true,"battery_health":"ok","co_alarm_state":"ok","smoke_alarm_state":"ok","ui_color_state":"green"},"a6789":{"name":"Downstairs Hallway","locale":"en-US","structure_id":"s1234","software_version":"1.0rc12","device_id":"a6789","name_long":"Downstairs Hallway Nest Protect","is_online":true,"last_connection":"2014-08-30T05:08:17.377Z","battery_health":"ok","co_alarm_state":"ok","smoke_alarm_state":"ok","ui_color_state":"green"}}}}`)
+	return []byte(`{"path":"/devices","data":{"thermostats":{"z1234":{"locale":"en-US","temperature_scale":"F","is_using_emergency_heat":false,"has_fan":true,"software_version":"4.1","has_leaf":true,"device_id":"z1234","name":"Entryway","can_heat":true,"can_cool":true,"hvac_mode":"heat","target_temperature_c":19.0,"target_temperature_f":67,"target_temperature_high_c":24.0,"target_temperature_high_f":75,"target_temperature_low_c":20.0,"target_temperature_low_f":68,"ambient_temperature_c":21.0,"ambient_temperature_f":70,"away_temperature_high_c":24.0,"away_temperature_high_f":76,"away_temperature_low_c":12.5,"away_temperature_low_f":55,"structure_id":"s1234","fan_timer_active":false,"name_long":"Entryway Thermostat","is_online":true},"z5678":{"locale":"en-US","temperature_scale":"F","is_using_emergency_heat":false,"has_fan":false,"software_version":"4.2.4","has_leaf":true,"device_id":"Zz5678","name":"Bedroom (Master)","can_heat":true,"can_cool":false,"hvac_mode":"heat","target_temperature_c":10.0,"target_temperature_f":50,"target_temperature_high_c":24.0,"target_temperature_high_f":75,"target_temperature_low_c":20.0,"target_temperature_low_f":68,"ambient_temperature_c":20.5,"ambient_temperature_f":70,"away_temperature_high_c":24.0,"away_temperature_high_f":76,"away_temperature_low_c":10.0,"away_temperature_low_f":50,"structure_id":"s1234","fan_timer_active":false,"name_long":"Bedroom Thermostat (Master)","is_online":true,"last_connection":"2014-08-30T16:29:45.165Z"}},"smoke_co_alarms":{"a1234":{"name":"Upstairs Hallway","locale":"en-US","structure_id":"a1234","software_version":"1.0rc12","device_id":"a1234","name_long":"Upstairs Hallway Nest Protect","is_online":true,"last_connection":"2014-08-30T05:35:47.025Z","battery_health":"ok","co_alarm_state":"ok","smoke_alarm_state":"ok","ui_color_state":"green"},"a3455":{"name":"Bedroom","locale":"en-US","structure_id":"s1234","software_version":"1.0.2rc2","device_id":"a3455","name_long":"Bedroom Nest Protect","is_online":true,"battery_health":"ok","co_alarm_state":"warning","smoke_alarm_state":"ok","ui_color_state":"green"},"a6789":{"name":"Downstairs Hallway","locale":"en-US","structure_id":"s1234","software_version":"1.0rc12","device_id":"a6789","name_long":"Downstairs Hallway Nest Protect","is_online":true,"last_connection":"2014-08-30T05:08:17.377Z","battery_health":"ok","co_alarm_state":"ok","smoke_alarm_state":"ok","ui_color_state":"green"}},"cameras":{"c1234":{"device_id":"c1234","software_version":"4.0","structure_id":"s1234","where_id":"w1234","where_name":"Front Door","name":"Front Door","name_long":"Front Door Camera","is_online":true,"is_streaming":true,"activity_zones":[{"name":"Walkway","id":244083}],"last_event":{"has_motion":true,"start_time":"2014-08-30T16:00:00.000Z","end_time":"2014-08-30T15:00:30.000Z","image_url":"https://example.com/image","animated_image_url":"https://example.com/animated","activity_zone_ids":["244083"]}}}}}`)
 }
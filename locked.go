@@ -0,0 +1,43 @@
+package nest
+
+const (
+	// LockedError is the APIError.Error for temperatures outside the range of a locked thermostat
+	LockedError = "locked_error"
+)
+
+/*
+LockedRange returns the temperatures a locked thermostat accepts, in the given scale.
+ok is false when the thermostat is not locked.
+https://developer.nest.com/documentation/api#locked_temp_min_f
+
+	min, max, ok := t.LockedRange(nest.Fahrenheit)
+*/
+func (t *Thermostat) LockedRange(scale Scale) (min Temperature, max Temperature, ok bool) {
+	if !t.IsLocked {
+		return min, max, false
+	}
+	if scale == Celsius {
+		min, max = TempC(float64(t.LockedTempMinC)), TempC(float64(t.LockedTempMaxC))
+	} else {
+		min, max = TempF(float64(t.LockedTempMinF)), TempF(float64(t.LockedTempMaxF))
+	}
+	if max.Value == 0 || max.Value < min.Value {
+		return min, max, false
+	}
+	return min, max, true
+}
+
+// checkLocked ensures a temperature is within the locked range, clamping it when the client asks for that
+func (t *Thermostat) checkLocked(temp Temperature) (Temperature, *APIError) {
+	min, max, ok := t.LockedRange(temp.Scale)
+	if !ok || (temp.Value >= min.Value && temp.Value <= max.Value) {
+		return temp, nil
+	}
+	if t.Client != nil && t.Client.ClampLockedTemps {
+		if temp.Value < min.Value {
+			return min, nil
+		}
+		return max, nil
+	}
+	return temp, generateError(LockedError, "Thermostat is locked - temperature must be between "+min.String()+" and "+max.String())
+}
@@ -0,0 +1,65 @@
+package nest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestLockedRange(t *testing.T) {
+	Convey("Given a locked thermostat", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.APIURL = ts.URL
+		devices, _ := client.Devices()
+		client.RedirectURL = ts.URL
+		thermostat := devices.Thermostats["z1234"]
+		thermostat.IsLocked = true
+		thermostat.LockedTempMinF = 65
+		thermostat.LockedTempMaxF = 75
+		thermostat.LockedTempMinC = 18.5
+		thermostat.LockedTempMaxC = 24
+
+		Convey("The range should be reported in either scale", func() {
+			min, max, ok := thermostat.LockedRange(Celsius)
+			So(ok, ShouldBeTrue)
+			So(min, ShouldResemble, TempC(18.5))
+			So(max, ShouldResemble, TempC(24))
+		})
+
+		Convey("A temperature inside the range should be accepted", func() {
+			err := thermostat.SetTargetTempF(70)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("A temperature outside the range should be rejected with the range", func() {
+			err := thermostat.SetTargetTempF(80)
+			So(err.Error, ShouldEqual, LockedError)
+			So(err.Description, ShouldEqual, "Thermostat is locked - temperature must be between 65°F and 75°F")
+			err = thermostat.SetTargetTempC(28.5)
+			So(err.Description, ShouldEqual, "Thermostat is locked - temperature must be between 18.5°C and 24°C")
+		})
+
+		Convey("High and low temperatures outside the range should be rejected", func() {
+			thermostat.HvacMode = HeatCool
+			err := thermostat.SetTargetTempHighLowF(78, 66)
+			So(err.Error, ShouldEqual, LockedError)
+		})
+
+		Convey("When clamping, a temperature outside the range should be moved to the bound", func() {
+			client.ClampLockedTemps = true
+			err := thermostat.SetTargetTempC(28.5)
+			So(err, ShouldBeNil)
+			temp, apiErr := thermostat.checkLocked(TempC(28.5))
+			So(apiErr, ShouldBeNil)
+			So(temp, ShouldResemble, TempC(24))
+		})
+
+		Convey("An unlocked thermostat should not be limited", func() {
+			thermostat.IsLocked = false
+			_, _, ok := thermostat.LockedRange(Fahrenheit)
+			So(ok, ShouldBeFalse)
+			err := thermostat.SetTargetTempF(80)
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package nest
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -13,24 +14,13 @@ const (
 	APIURL = "https://developer-api.nest.com"
 	// AccessTokenURL is the Next API URL to get an access_token
 	AccessTokenURL = "https://api.home.nest.com/oauth2/access_token"
-	// NoStream indicates wedo not want to stream on a GET for server side events
+)
+
+const (
+	// NoStream indicates we do not want to stream on a GET for server side events
 	NoStream = iota
-	//Stream indicates we want to stream on a GET for server side events
+	// Stream indicates we want to stream on a GET for server side events
 	Stream
-	// Cool sets HvacMode to "cool"
-	Cool
-	// Heat sets HvacMode to "heat"
-	Heat
-	// HeatCool sets HvacMode to "heat-cool"
-	HeatCool
-	// Off sets HvacMode to "off"
-	Off
-	// Home sets Away mode to "home"
-	Home
-	// Away sets Away mode to "away"
-	Away
-	// AutoAway sets Away mode to "auto-away"
-	AutoAway
 )
 
 /*
@@ -56,13 +46,11 @@ https://developer.nest.com/documentation/how-to-auth
 	client.Authorize()
 */
 func (c *Client) Authorize() *APIError {
-	resp, err := http.Post(c.authURL(), "application/x-www-form-urlencoded", nil)
+	resp, err := c.httpClient().Post(c.authURL(), "application/x-www-form-urlencoded", nil)
 	if err != nil {
 		return &APIError{
 			Error:       "http_error",
 			Description: err.Error(),
-			Status:      resp.Status,
-			StatusCode:  resp.StatusCode,
 		}
 	}
 	defer resp.Body.Close()
@@ -121,8 +109,8 @@ func (c *Client) Devices() (*Devices, *APIError) {
 func (c *Client) getDevices(action int) (*http.Response, error) {
 	if c.RedirectURL == "" {
 		req, _ := http.NewRequest("GET", c.APIURL+"/devices.json?auth="+c.Token, nil)
-		resp, err := http.DefaultClient.Do(req)
-		if resp.Request.URL != nil {
+		resp, err := c.httpClient().Do(req)
+		if err == nil && resp.Request.URL != nil {
 			c.RedirectURL = resp.Request.URL.Scheme + "://" + resp.Request.URL.Host
 		}
 		return resp, err
@@ -132,10 +120,41 @@ func (c *Client) getDevices(action int) (*http.Response, error) {
 	if action == Stream {
 		req.Header.Set("Accept", "text/event-stream")
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient().Do(req)
 	return resp, err
 }
 
+// put sends a PUT request to the Nest REST API and returns the body of a successful response
+func (c *Client) put(path string, body []byte) ([]byte, *APIError) {
+	return c.send("PUT", path, body)
+}
+
+// send sends a request to the Nest REST API and returns the body of a successful response
+func (c *Client) send(method string, path string, body []byte) ([]byte, *APIError) {
+	url := c.RedirectURL + path + "?auth=" + c.Token
+	req, _ := http.NewRequest(method, url, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		apiError := &APIError{
+			Error:       "http_error",
+			Description: err.Error(),
+		}
+		return nil, apiError
+	}
+	defer resp.Body.Close()
+	body, _ = ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		apiError := &APIError{}
+		json.Unmarshal(body, apiError)
+		apiError = generateAPIError(apiError.Error)
+		apiError.Status = resp.Status
+		apiError.StatusCode = resp.StatusCode
+		return nil, apiError
+	}
+	return body, nil
+}
+
 // authURL sets the full authorization URL for the Nest API
 func (c *Client) authURL() string {
 	location := c.AccessTokenURL + "?code=" + c.AuthorizationCode
@@ -153,14 +172,29 @@ func (c *Client) associateClientToDevices(devices *Devices) {
 	for _, value := range devices.SmokeCoAlarms {
 		value.Client = c
 	}
+	for _, value := range devices.Cameras {
+		value.Client = c
+	}
+}
+
+// httpClient returns the HTTP client to send requests with
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
 }
 
 // setRedirectURL sets the URL if not already set
 func (c *Client) setRedirectURL() (int, error) {
 	if c.RedirectURL == "" {
 		resp, err := c.getDevices(NoStream)
-		if err != nil || resp.StatusCode != 200 {
-			return resp.StatusCode, err
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return resp.StatusCode, nil
 		}
 	}
 	return 0, nil
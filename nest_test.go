@@ -3,6 +3,7 @@ package nest
 import (
 	. "github.com/smartystreets/goconvey/convey"
 	"net/http/httptest"
+	"os"
 	"testing"
 )
 
@@ -17,8 +18,12 @@ const (
 
 var ts *httptest.Server
 
+func TestMain(m *testing.M) {
+	ts = serveHTTP(nil)
+	os.Exit(m.Run())
+}
+
 func TestNew(t *testing.T) {
-	ts = serveHTTP(t)
 
 	Convey("Given a client ID and state we should be able to create a new client", t, func() {
 		client := New(ClientID, State, ClientSecret, BadAuthorizationCode)
@@ -61,5 +66,6 @@ func TestDevices(t *testing.T) {
 		checkFields(devices)
 		So(client.Token, ShouldEqual, devices.Thermostats["z1234"].Client.Token)
 		So(client.Token, ShouldEqual, devices.SmokeCoAlarms["z5678"].Client.Token)
+		So(client.Token, ShouldEqual, devices.Cameras["c1234"].Client.Token)
 	})
 }
@@ -0,0 +1,497 @@
+/*
+Package nesttest provides a fake Nest API server for testing code built on the nest package.
+
+The server keeps devices and structures in memory, follows the Nest API by redirecting
+requests from its front URL to a backend URL, applies writes with the validation rules of the
+Nest API and streams server side events to clients whenever the data they watch changes.
+Errors, latency and revoked authorizations can be injected to exercise failure handling, and
+a Simulation can move thermostat temperatures like a real house faster than real time.
+
+	server := nesttest.NewServer()
+	defer server.Close()
+	server.AddThermostat(&nest.Thermostat{DeviceID: "t1", CanHeat: true, HvacMode: nest.Heat})
+	client := server.Client()
+	devices, _ := client.Devices()
+*/
+package nesttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsgoecke/nest"
+)
+
+const (
+	// Token is the access token a new Server accepts
+	Token = "c.nesttest-token"
+	// AuthorizationCode is the authorization code a new Server exchanges for Token
+	AuthorizationCode = "nesttest-code"
+	// keepAlive is how often streams send a keep-alive event
+	keepAlive = 30 * time.Second
+)
+
+// Server is a fake Nest API server
+type Server struct {
+	URL               string
+	RedirectURL       string
+	AccessTokenURL    string
+	Token             string
+	AuthorizationCode string
+	front             *httptest.Server
+	backend           *httptest.Server
+	mu                sync.Mutex
+	tree              map[string]interface{}
+	failures          []*failure
+	latency           time.Duration
+	revoked           bool
+	streams           map[chan struct{}]bool
+	whereCount        int
+	drop              chan struct{}
+	done              chan struct{}
+	closeOnce         sync.Once
+}
+
+// failure is an error to return for the next request matching its method and path
+type failure struct {
+	method  string
+	path    string
+	status  int
+	message string
+}
+
+/*
+NewServer starts a new fake Nest API server with no devices or structures
+
+	server := nesttest.NewServer()
+	defer server.Close()
+*/
+func NewServer() *Server {
+	s := &Server{
+		Token:             Token,
+		AuthorizationCode: AuthorizationCode,
+		tree:              emptyTree(),
+		streams:           make(map[chan struct{}]bool),
+		drop:              make(chan struct{}),
+		done:              make(chan struct{}),
+	}
+	s.backend = httptest.NewServer(http.HandlerFunc(s.serveBackend))
+	s.front = httptest.NewServer(http.HandlerFunc(s.serveFront))
+	s.URL = s.front.URL
+	s.RedirectURL = s.backend.URL
+	s.AccessTokenURL = s.front.URL + "/oauth2/access_token"
+	return s
+}
+
+/*
+NewHome starts a new fake Nest API server holding a home with a heating thermostat, a quiet smoke and CO
+alarm and an offline camera, for tests that need a typical account. Tests change what they need with Set.
+
+	server := nesttest.NewHome()
+	defer server.Close()
+	server.Set("structures/s1/away", "away")
+*/
+func NewHome() *Server {
+	s := NewServer()
+	s.AddThermostat(&nest.Thermostat{
+		DeviceID:            "t1",
+		StructureID:         "s1",
+		Name:                "Hallway",
+		NameLong:            "Hallway Thermostat",
+		WhereName:           "Hallway",
+		IsOnline:            true,
+		CanHeat:             true,
+		CanCool:             true,
+		HasFan:              true,
+		HvacMode:            nest.Heat,
+		HvacState:           nest.Heating,
+		TemperatureScale:    nest.Fahrenheit,
+		AmbientTemperatureF: 66,
+		AmbientTemperatureC: 19,
+		TargetTemperatureF:  68,
+		TargetTemperatureC:  20,
+		Humidity:            40,
+	})
+	s.AddSmokeCoAlarm(&nest.SmokeCoAlarm{
+		DeviceID:        "a1",
+		StructureID:     "s1",
+		NameLong:        "Kitchen Protect",
+		WhereName:       "Kitchen",
+		IsOnline:        true,
+		CoAlarmState:    nest.AlarmOK,
+		SmokeAlarmState: nest.AlarmOK,
+		BatteryHealth:   nest.BatteryOK,
+	})
+	s.AddCamera(&nest.Camera{DeviceID: "c1", StructureID: "s1", NameLong: "Porch Camera", WhereName: "Porch"})
+	s.AddStructure(&nest.Structure{
+		StructureID:     "s1",
+		Name:            "Home",
+		Away:            nest.Home,
+		CoAlarmState:    nest.AlarmOK,
+		SmokeAlarmState: nest.AlarmOK,
+		Thermostats:     []string{"t1"},
+		SmokeCoAlarms:   []string{"a1"},
+		Cameras:         []string{"c1"},
+	})
+	return s
+}
+
+// Close ends all streams and shuts the server down
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.front.Close()
+		s.backend.Close()
+	})
+}
+
+/*
+Client creates a nest client that talks to the server and is already authorized
+
+	client := server.Client()
+*/
+func (s *Server) Client() *nest.Client {
+	client := nest.New("nesttest", "STATE", "nesttest-secret", s.AuthorizationCode)
+	client.APIURL = s.URL
+	client.AccessTokenURL = s.AccessTokenURL
+	client.Token = s.Token
+	return client
+}
+
+// Load replaces all data held by the server with a JSON document holding devices and structures
+func (s *Server) Load(data []byte) error {
+	tree := emptyTree()
+	err := json.Unmarshal(data, &tree)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.tree = tree
+	s.mu.Unlock()
+	s.changed()
+	return nil
+}
+
+/*
+Set stores value as JSON at the slash separated path, creating any missing parents. A nil value removes the path.
+
+	server.Set("devices/thermostats/t1/ambient_temperature_f", 68)
+*/
+func (s *Server) Set(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var decoded interface{}
+	json.Unmarshal(data, &decoded)
+	s.mu.Lock()
+	set(s.tree, splitPath(path), decoded)
+	s.mu.Unlock()
+	s.changed()
+	return nil
+}
+
+/*
+Get decodes the JSON stored at the slash separated path into value
+
+	thermostat := &nest.Thermostat{}
+	server.Get("devices/thermostats/t1", thermostat)
+*/
+func (s *Server) Get(path string, value interface{}) error {
+	s.mu.Lock()
+	data, _ := json.Marshal(lookup(s.tree, splitPath(path)))
+	s.mu.Unlock()
+	return json.Unmarshal(data, value)
+}
+
+// AddThermostat stores a thermostat under its device ID
+func (s *Server) AddThermostat(thermostat *nest.Thermostat) error {
+	return s.Set("devices/thermostats/"+thermostat.DeviceID, thermostat)
+}
+
+// AddSmokeCoAlarm stores a smokecoalarm under its device ID
+func (s *Server) AddSmokeCoAlarm(alarm *nest.SmokeCoAlarm) error {
+	return s.Set("devices/smoke_co_alarms/"+alarm.DeviceID, alarm)
+}
+
+// AddCamera stores a camera under its device ID
+func (s *Server) AddCamera(camera *nest.Camera) error {
+	return s.Set("devices/cameras/"+camera.DeviceID, camera)
+}
+
+// AddStructure stores a structure under its structure ID
+func (s *Server) AddStructure(structure *nest.Structure) error {
+	return s.Set("structures/"+structure.StructureID, structure)
+}
+
+/*
+FailNext makes the next request with the method to the path fail with the status and error message.
+The path is matched without its .json suffix, and an empty method matches any method.
+
+	server.FailNext("PUT", "/devices/thermostats/t1", 503, "service_unavailable")
+*/
+func (s *Server) FailNext(method string, path string, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{
+		method:  method,
+		path:    strings.TrimSuffix(path, ".json"),
+		status:  status,
+		message: message,
+	})
+}
+
+// SetLatency delays every response by the duration
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// RevokeAuth revokes the token, ending open streams with an auth_revoked event until the client authorizes again
+func (s *Server) RevokeAuth() {
+	s.mu.Lock()
+	s.revoked = true
+	s.mu.Unlock()
+	s.changed()
+}
+
+// OpenStreams returns how many streams are connected
+func (s *Server) OpenStreams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.streams)
+}
+
+// CloseStreams ends every open stream, as when the Nest API drops connections, so clients have to reconnect
+func (s *Server) CloseStreams() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	close(s.drop)
+	s.drop = make(chan struct{})
+}
+
+// serveFront handles the token endpoint and redirects everything else to the backend
+func (s *Server) serveFront(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/oauth2/access_token" {
+		s.serveToken(w, req)
+		return
+	}
+	if !s.intercept(w, req, false) {
+		return
+	}
+	http.Redirect(w, req, s.RedirectURL+req.URL.RequestURI(), http.StatusTemporaryRedirect)
+}
+
+// serveToken exchanges the authorization code for the token
+func (s *Server) serveToken(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if req.Method != "POST" || query.Get("grant_type") != "authorization_code" || query.Get("code") != s.AuthorizationCode {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"oauth2_error","error_description":"authorization code not found"}`))
+		return
+	}
+	s.mu.Lock()
+	s.revoked = false
+	s.mu.Unlock()
+	access, _ := json.Marshal(&nest.Access{Token: s.Token, ExpiresIn: 315360000})
+	w.Write(access)
+}
+
+// serveBackend reads, writes and streams the data held by the server
+func (s *Server) serveBackend(w http.ResponseWriter, req *http.Request) {
+	if !s.intercept(w, req, true) {
+		return
+	}
+	path := splitPath(strings.TrimSuffix(req.URL.Path, ".json"))
+	if req.Method == "GET" {
+		if req.Header.Get("Accept") == "text/event-stream" {
+			s.serveStream(w, req, path)
+			return
+		}
+		s.mu.Lock()
+		data, _ := json.Marshal(lookup(s.tree, path))
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+		return
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	changes := make(map[string]interface{})
+	if json.Unmarshal(body, &changes) != nil {
+		writeError(w, http.StatusBadRequest, "Invalid content sent")
+		return
+	}
+	s.mu.Lock()
+	response, status, message := s.write(req.Method, path, changes)
+	s.mu.Unlock()
+	if status != http.StatusOK {
+		writeError(w, status, message)
+		return
+	}
+	s.changed()
+	data, _ := json.Marshal(response)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// serveStream sends the data at path whenever it changes, until the client goes away or the token is revoked
+func (s *Server) serveStream(w http.ResponseWriter, req *http.Request, path []string) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	changed := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.streams[changed] = true
+	drop := s.drop
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, changed)
+		s.mu.Unlock()
+	}()
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	last := ""
+	for {
+		s.mu.Lock()
+		revoked := s.revoked
+		data, _ := json.Marshal(lookup(s.tree, path))
+		s.mu.Unlock()
+		if revoked {
+			fmt.Fprintf(w, "event: auth_revoked\ndata: %q\n\n", s.Token)
+			flusher.Flush()
+			return
+		}
+		if string(data) != last {
+			last = string(data)
+			fmt.Fprintf(w, "event: put\ndata: {\"path\":\"/\",\"data\":%s}\n\n", data)
+			flusher.Flush()
+		}
+		select {
+		case <-changed:
+		case <-ticker.C:
+			fmt.Fprint(w, "event: keep-alive\ndata: null\n\n")
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		case <-drop:
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// intercept applies latency, injected failures and the token check, returning false if the request was answered.
+// Latency is only applied where the request is answered, so a redirected request is delayed once.
+func (s *Server) intercept(w http.ResponseWriter, req *http.Request, backend bool) bool {
+	s.mu.Lock()
+	latency := s.latency
+	var injected *failure
+	path := strings.TrimSuffix(req.URL.Path, ".json")
+	for i, f := range s.failures {
+		if (f.method == "" || f.method == req.Method) && f.path == path {
+			injected = f
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			break
+		}
+	}
+	authorized := req.URL.Query().Get("auth") == s.Token && !s.revoked
+	s.mu.Unlock()
+	if latency > 0 && (backend || injected != nil || !authorized) {
+		time.Sleep(latency)
+	}
+	if injected != nil {
+		writeError(w, injected.status, injected.message)
+		return false
+	}
+	if !authorized {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return false
+	}
+	return true
+}
+
+// changed wakes every open stream so it can send data that changed
+func (s *Server) changed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for stream := range s.streams {
+		select {
+		case stream <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// writeError writes an error response the way the Nest API does
+func writeError(w http.ResponseWriter, status int, message string) {
+	data, _ := json.Marshal(map[string]string{"error": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// emptyTree returns the data of a server with no devices or structures
+func emptyTree() map[string]interface{} {
+	return map[string]interface{}{
+		"devices": map[string]interface{}{
+			"thermostats":     map[string]interface{}{},
+			"smoke_co_alarms": map[string]interface{}{},
+			"cameras":         map[string]interface{}{},
+		},
+		"structures": map[string]interface{}{},
+	}
+}
+
+// splitPath splits a slash separated path into keys
+func splitPath(path string) []string {
+	keys := []string{}
+	for _, key := range strings.Split(path, "/") {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// lookup walks down the tree following keys, returning nil if there is nothing there
+func lookup(tree interface{}, keys []string) interface{} {
+	for _, key := range keys {
+		object, ok := tree.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		tree = object[key]
+	}
+	return tree
+}
+
+// set stores value in the tree at keys, creating any missing parents
+func set(tree map[string]interface{}, keys []string, value interface{}) {
+	if len(keys) == 0 {
+		return
+	}
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := tree[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			tree[key] = child
+		}
+		tree = child
+	}
+	if value == nil {
+		delete(tree, keys[len(keys)-1])
+		return
+	}
+	tree[keys[len(keys)-1]] = value
+}
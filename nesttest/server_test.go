@@ -0,0 +1,150 @@
+package nesttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jsgoecke/nest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// request sends a request straight to the backend, returning the status and decoded body
+func request(server *Server, method string, path string, body string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest(method, server.RedirectURL+path+"?auth="+server.Token, bytes.NewBufferString(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	decoded := make(map[string]interface{})
+	json.Unmarshal(data, &decoded)
+	return resp.StatusCode, decoded
+}
+
+func TestServer(t *testing.T) {
+	Convey("When authorizing against the server", t, func() {
+		server := NewServer()
+		defer server.Close()
+		client := server.Client()
+		client.Token = ""
+		So(client.Authorize(), ShouldBeNil)
+		So(client.Token, ShouldEqual, Token)
+
+		client.AuthorizationCode = "wrong"
+		err := client.Authorize()
+		So(err.Error, ShouldEqual, "oauth2_error")
+	})
+
+	Convey("Closing the server twice should not panic", t, func() {
+		server := NewServer()
+		server.Close()
+		So(server.Close, ShouldNotPanic)
+	})
+
+	Convey("When reading devices and structures", t, func() {
+		server := NewHome()
+		defer server.Close()
+		client := server.Client()
+		devices, err := client.Devices()
+		So(err, ShouldBeNil)
+		So(client.RedirectURL, ShouldEqual, server.RedirectURL)
+		So(devices.Thermostats["t1"].Name, ShouldEqual, "Hallway")
+		structures, err := client.Structures()
+		So(err, ShouldBeNil)
+		So(structures["s1"].Thermostats, ShouldResemble, []string{"t1"})
+
+		Convey("Writes through the client should be stored", func() {
+			So(devices.Thermostats["t1"].SetTarget(nest.TempF(71)), ShouldBeNil)
+			So(structures["s1"].SetAway(nest.Away), ShouldBeNil)
+			thermostat := &nest.Thermostat{}
+			server.Get("devices/thermostats/t1", thermostat)
+			So(thermostat.TargetTemperatureF, ShouldEqual, 71)
+			So(thermostat.TargetTemperatureC, ShouldEqual, 21.5)
+			structure := &nest.Structure{}
+			server.Get("structures/s1", structure)
+			So(structure.Away, ShouldEqual, nest.Away)
+		})
+		Convey("Writes should be confirmed by the stream", func() {
+			client.ConfirmTimeout = 2 * time.Second
+			So(devices.Thermostats["t1"].SetHvacMode(nest.Cool), ShouldBeNil)
+			So(devices.Thermostats["t1"].PreviousHvacMode, ShouldEqual, nest.Heat)
+		})
+		Convey("ETAs and wheres should be accepted", func() {
+			So(structures["s1"].SetETA("trip", time.Now().Add(time.Minute), time.Now().Add(10*time.Minute)), ShouldBeNil)
+			eta := &nest.ETA{}
+			server.Get("structures/s1/eta", eta)
+			So(eta.TripID, ShouldEqual, "trip")
+			So(structures["s1"].CancelETA("trip"), ShouldBeNil)
+			So(server.Get("structures/s1/eta", &eta), ShouldBeNil)
+			So(eta, ShouldBeNil)
+
+			where, err := structures["s1"].CreateWhere("Attic")
+			So(err, ShouldBeNil)
+			So(where.WhereID, ShouldEqual, "nesttest-where-1")
+		})
+	})
+
+	Convey("When streaming devices", t, func() {
+		server := NewHome()
+		defer server.Close()
+		client := server.Client()
+		updates := make(chan *nest.Devices, 10)
+		go client.DevicesStream(func(devices *nest.Devices, err error) {
+			if err == nil {
+				updates <- devices
+			}
+		})
+		first := <-updates
+		So(first.Thermostats["t1"].AmbientTemperatureF, ShouldEqual, 66)
+		server.Set("devices/thermostats/t1/ambient_temperature_f", 64)
+		second := <-updates
+		So(second.Thermostats["t1"].AmbientTemperatureF, ShouldEqual, 64)
+	})
+
+	Convey("When injecting failures", t, func() {
+		server := NewHome()
+		defer server.Close()
+		client := server.Client()
+		devices, _ := client.Devices()
+
+		Convey("The next matching request should fail once", func() {
+			server.FailNext("PUT", "/devices/thermostats/t1", 503, "service_unavailable")
+			err := devices.Thermostats["t1"].SetLabel("Upstairs")
+			So(err.StatusCode, ShouldEqual, 503)
+			So(err.Description, ShouldEqual, "service_unavailable")
+			So(devices.Thermostats["t1"].SetLabel("Upstairs"), ShouldBeNil)
+		})
+		Convey("Latency should delay responses", func() {
+			server.SetLatency(50 * time.Millisecond)
+			start := time.Now()
+			client.Devices()
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+		})
+		Convey("Latency should delay a redirected request once", func() {
+			server.SetLatency(200 * time.Millisecond)
+			start := time.Now()
+			_, err := server.Client().Devices()
+			So(err, ShouldBeNil)
+			So(time.Since(start), ShouldBeBetween, 200*time.Millisecond, 350*time.Millisecond)
+		})
+		Convey("Revoking the token should end streams and reject requests", func() {
+			req, _ := http.NewRequest("GET", server.RedirectURL+"/devices.json?auth="+server.Token, nil)
+			req.Header.Set("Accept", "text/event-stream")
+			resp, _ := http.DefaultClient.Do(req)
+			server.RevokeAuth()
+			data, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			So(string(data), ShouldContainSubstring, "event: auth_revoked")
+			_, err := client.Devices()
+			So(err.Error, ShouldEqual, "unauthorized")
+			So(client.Authorize(), ShouldBeNil)
+			_, err = client.Devices()
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,192 @@
+package nesttest
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// simulationStep is the longest stretch of simulated time moved in one go, keeping Step accurate for long durations
+const simulationStep = time.Minute
+
+/*
+Simulation moves the ambient temperature of every thermostat on a server like a house would. Thermostats
+heat or cool toward their targets according to their hvac_mode, reporting the matching hvac_state, and
+every house drifts toward the outdoor temperature. Temperatures are in farenheit and rates are per hour
+of simulated time. The fields are read while the simulation runs, so set them before Start or after Stop.
+*/
+type Simulation struct {
+	Server     *Server
+	OutdoorF   float64
+	HeatRate   float64
+	CoolRate   float64
+	DriftRate  float64
+	Hysteresis float64
+	mu         sync.Mutex
+	ambient    map[string]float64
+	stop       chan struct{}
+	stopped    chan struct{}
+	speed      float64
+}
+
+/*
+Simulate creates a Simulation of the server's thermostats with mild weather, running an hour every minute once started
+
+	simulation := server.Simulate()
+	simulation.OutdoorF = 30
+	simulation.Step(2 * time.Hour)
+*/
+func (s *Server) Simulate() *Simulation {
+	return &Simulation{
+		Server:     s,
+		OutdoorF:   55,
+		HeatRate:   6,
+		CoolRate:   6,
+		DriftRate:  0.1,
+		Hysteresis: 1,
+		speed:      60,
+		ambient:    make(map[string]float64),
+	}
+}
+
+// Step advances the simulation by the duration of simulated time and streams the new temperatures
+func (sim *Simulation) Step(duration time.Duration) {
+	sim.mu.Lock()
+	sim.Server.mu.Lock()
+	thermostats, _ := lookup(sim.Server.tree, []string{"devices", "thermostats"}).(map[string]interface{})
+	for id, value := range thermostats {
+		thermostat, ok := value.(map[string]interface{})
+		if ok {
+			sim.stepThermostat(id, thermostat, duration)
+		}
+	}
+	sim.Server.mu.Unlock()
+	sim.mu.Unlock()
+	sim.Server.changed()
+}
+
+/*
+Start steps the simulation every interval of real time, by the interval times its speed, until Stop is called
+
+	simulation.Start(time.Second)
+	defer simulation.Stop()
+*/
+func (sim *Simulation) Start(interval time.Duration) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	if sim.stop != nil {
+		return
+	}
+	sim.stop = make(chan struct{})
+	sim.stopped = make(chan struct{})
+	go sim.run(interval, sim.stop, sim.stopped)
+}
+
+/*
+SetSpeed sets how many times faster than real time a started simulation runs, which may be changed while it runs
+
+	simulation.SetSpeed(3600)
+*/
+func (sim *Simulation) SetSpeed(speed float64) {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+	sim.speed = speed
+}
+
+// Stop stops a started simulation
+func (sim *Simulation) Stop() {
+	sim.mu.Lock()
+	stop, stopped := sim.stop, sim.stopped
+	sim.stop, sim.stopped = nil, nil
+	sim.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
+
+// run steps the simulation on a ticker until stop is closed
+func (sim *Simulation) run(interval time.Duration, stop chan struct{}, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sim.mu.Lock()
+			speed := sim.speed
+			sim.mu.Unlock()
+			sim.Step(time.Duration(float64(interval) * speed))
+		case <-stop:
+			return
+		case <-sim.Server.done:
+			return
+		}
+	}
+}
+
+// stepThermostat runs one thermostat for the duration, in steps of at most simulationStep
+func (sim *Simulation) stepThermostat(id string, thermostat map[string]interface{}, duration time.Duration) {
+	reported, _ := thermostat["ambient_temperature_f"].(float64)
+	ambient, known := sim.ambient[id]
+	if !known || math.Round(ambient) != reported {
+		ambient = reported
+	}
+	state, _ := thermostat["hvac_state"].(string)
+	for duration > 0 {
+		step := duration
+		if step > simulationStep {
+			step = simulationStep
+		}
+		duration -= step
+		hours := step.Hours()
+		state = sim.hvacState(thermostat, state, ambient)
+		switch state {
+		case "heating":
+			ambient += sim.HeatRate * hours
+		case "cooling":
+			ambient -= sim.CoolRate * hours
+		}
+		ambient += (sim.OutdoorF - ambient) * (1 - math.Exp(-sim.DriftRate*hours))
+	}
+	sim.ambient[id] = ambient
+	thermostat["ambient_temperature_f"] = math.Round(ambient)
+	thermostat["ambient_temperature_c"] = math.Round((ambient-32)*5/9*2) / 2
+	thermostat["hvac_state"] = state
+}
+
+// hvacState decides what the system is doing, starting once the ambient is Hysteresis past a target and stopping at it
+func (sim *Simulation) hvacState(thermostat map[string]interface{}, state string, ambient float64) string {
+	var heatTo, coolTo float64
+	heat, cool := false, false
+	mode, _ := thermostat["hvac_mode"].(string)
+	switch mode {
+	case "heat":
+		heatTo, heat = number(thermostat, "target_temperature_f"), true
+	case "cool":
+		coolTo, cool = number(thermostat, "target_temperature_f"), true
+	case "heat-cool":
+		heatTo, heat = number(thermostat, "target_temperature_low_f"), true
+		coolTo, cool = number(thermostat, "target_temperature_high_f"), true
+	case "eco":
+		heatTo, coolTo = number(thermostat, "eco_temperature_low_f"), number(thermostat, "eco_temperature_high_f")
+		heat, cool = heatTo > 0, coolTo > 0
+	}
+	switch {
+	case heat && state == "heating" && ambient < heatTo:
+		return "heating"
+	case heat && ambient <= heatTo-sim.Hysteresis:
+		return "heating"
+	case cool && state == "cooling" && ambient > coolTo:
+		return "cooling"
+	case cool && ambient >= coolTo+sim.Hysteresis:
+		return "cooling"
+	}
+	return "off"
+}
+
+// number returns a numeric field of an object, or zero
+func number(object map[string]interface{}, key string) float64 {
+	value, _ := object[key].(float64)
+	return value
+}
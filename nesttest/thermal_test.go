@@ -0,0 +1,102 @@
+package nesttest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jsgoecke/nest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// ambient reads the simulated thermostat back from the server
+func ambient(server *Server) *nest.Thermostat {
+	thermostat := &nest.Thermostat{}
+	server.Get("devices/thermostats/t1", thermostat)
+	return thermostat
+}
+
+func TestSimulation(t *testing.T) {
+	Convey("When simulating a house", t, func() {
+		server := NewHome()
+		defer server.Close()
+		server.Set("devices/thermostats/t1/ambient_temperature_f", 60)
+		simulation := server.Simulate()
+
+		Convey("A heating thermostat should warm the house up to its target and stop", func() {
+			simulation.Step(10 * time.Minute)
+			So(ambient(server).HvacState, ShouldEqual, nest.Heating)
+			So(ambient(server).AmbientTemperatureF, ShouldEqual, 61)
+			simulation.Step(3 * time.Hour)
+			So(ambient(server).HvacState, ShouldEqual, nest.Idle)
+			So(ambient(server).AmbientTemperatureF, ShouldBeBetweenOrEqual, 67, 68)
+		})
+		Convey("Steps should be deterministic however they are split", func() {
+			other := NewHome()
+			defer other.Close()
+			other.Set("devices/thermostats/t1/ambient_temperature_f", 60)
+			otherSimulation := other.Simulate()
+			simulation.Step(90 * time.Minute)
+			for i := 0; i < 90; i++ {
+				otherSimulation.Step(time.Minute)
+			}
+			So(simulation.ambient["t1"], ShouldAlmostEqual, otherSimulation.ambient["t1"], 0.0001)
+		})
+		Convey("A house with the system off should drift toward the outdoor temperature", func() {
+			server.Set("devices/thermostats/t1/hvac_mode", "off")
+			simulation.OutdoorF = 30
+			simulation.Step(24 * time.Hour)
+			thermostat := ambient(server)
+			So(thermostat.HvacState, ShouldEqual, nest.Idle)
+			So(thermostat.AmbientTemperatureF, ShouldBeBetween, 30, 40)
+			So(thermostat.AmbientTemperatureC, ShouldBeLessThan, 5)
+		})
+		Convey("A cooling thermostat should bring a hot house down", func() {
+			server.Set("devices/thermostats/t1/hvac_mode", "cool")
+			server.Set("devices/thermostats/t1/target_temperature_f", 74)
+			server.Set("devices/thermostats/t1/ambient_temperature_f", 82)
+			simulation.OutdoorF = 95
+			simulation.Step(10 * time.Minute)
+			So(ambient(server).HvacState, ShouldEqual, nest.Cooling)
+			simulation.Step(4 * time.Hour)
+			So(ambient(server).AmbientTemperatureF, ShouldBeBetweenOrEqual, 73, 75)
+		})
+		Convey("Eco mode should hold the eco temperatures", func() {
+			server.Set("devices/thermostats/t1/hvac_mode", "eco")
+			server.Set("devices/thermostats/t1/eco_temperature_low_f", 55)
+			simulation.OutdoorF = 20
+			simulation.Step(24 * time.Hour)
+			So(ambient(server).AmbientTemperatureF, ShouldBeBetweenOrEqual, 54, 55)
+		})
+		Convey("Temperatures set on the server should be picked up", func() {
+			simulation.Step(time.Minute)
+			server.Set("devices/thermostats/t1/ambient_temperature_f", 75)
+			simulation.Step(time.Minute)
+			So(ambient(server).AmbientTemperatureF, ShouldEqual, 75)
+		})
+		Convey("A started simulation should stream changes until stopped", func() {
+			client := server.Client()
+			updates := make(chan *nest.Devices, 100)
+			go client.DevicesStream(func(devices *nest.Devices, err error) {
+				if err == nil {
+					updates <- devices
+				}
+			})
+			So((<-updates).Thermostats["t1"].AmbientTemperatureF, ShouldEqual, 60)
+			simulation.Start(10 * time.Millisecond)
+			simulation.SetSpeed(3600)
+			warmed := false
+			timeout := time.After(5 * time.Second)
+			for !warmed {
+				select {
+				case devices := <-updates:
+					warmed = devices.Thermostats["t1"].AmbientTemperatureF > 60
+				case <-timeout:
+					warmed = true
+				}
+			}
+			So(ambient(server).AmbientTemperatureF, ShouldBeGreaterThan, 60)
+			simulation.Stop()
+			simulation.Stop()
+		})
+	})
+}
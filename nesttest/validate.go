@@ -0,0 +1,281 @@
+package nesttest
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// thermostatFields are the thermostat fields the Nest API allows writing
+var thermostatFields = map[string]bool{
+	"fan_timer_active":          true,
+	"fan_timer_duration":        true,
+	"hvac_mode":                 true,
+	"label":                     true,
+	"temperature_scale":         true,
+	"target_temperature_f":      true,
+	"target_temperature_c":      true,
+	"target_temperature_high_f": true,
+	"target_temperature_high_c": true,
+	"target_temperature_low_f":  true,
+	"target_temperature_low_c":  true,
+}
+
+// fanTimerDurations are the fan timer durations in minutes the Nest API accepts
+var fanTimerDurations = map[float64]bool{15: true, 30: true, 45: true, 60: true, 120: true, 240: true, 480: true, 720: true, 960: true}
+
+// write applies a PUT or POST to the tree, returning the response or the status and message of an error.
+// The caller must hold the lock.
+func (s *Server) write(method string, path []string, changes map[string]interface{}) (interface{}, int, string) {
+	if method == "POST" && len(path) == 3 && path[0] == "structures" && path[2] == "wheres" {
+		return s.createWhere(path[1], changes)
+	}
+	if method != "PUT" {
+		return nil, http.StatusMethodNotAllowed, "Method not allowed"
+	}
+	switch {
+	case len(path) == 3 && path[0] == "devices":
+		device, _ := lookup(s.tree, path).(map[string]interface{})
+		if device == nil {
+			break
+		}
+		switch path[1] {
+		case "thermostats":
+			return s.writeThermostat(device, changes)
+		case "cameras":
+			return writeFields(device, changes, map[string]string{"is_streaming": "bool"})
+		}
+		return nil, http.StatusForbidden, "No write permission(s) for field(s): " + strings.Join(sortedKeys(changes), ", ")
+	case len(path) == 2 && path[0] == "structures":
+		structure, _ := lookup(s.tree, path).(map[string]interface{})
+		if structure == nil {
+			break
+		}
+		if away, ok := changes["away"]; ok && away != "home" && away != "away" {
+			return nil, http.StatusBadRequest, "Invalid value for away"
+		}
+		return writeFields(structure, changes, map[string]string{"away": "string", "name": "string"})
+	case len(path) == 3 && path[0] == "structures" && path[2] == "eta":
+		structure, _ := lookup(s.tree, path[:2]).(map[string]interface{})
+		if structure == nil {
+			break
+		}
+		return writeETA(structure, changes)
+	}
+	return nil, http.StatusNotFound, "Not found"
+}
+
+// writeFields applies changes to object after checking each is one of the writable fields and of its JSON type
+func writeFields(object map[string]interface{}, changes map[string]interface{}, fields map[string]string) (interface{}, int, string) {
+	for _, key := range sortedKeys(changes) {
+		kind, ok := fields[key]
+		if !ok {
+			return nil, http.StatusForbidden, "No write permission(s) for field(s): " + key
+		}
+		if jsonType(changes[key]) != kind {
+			return nil, http.StatusBadRequest, "Invalid value for " + key
+		}
+	}
+	for key, value := range changes {
+		object[key] = value
+	}
+	return changes, http.StatusOK, ""
+}
+
+// writeThermostat applies changes to a thermostat following the rules of the Nest API
+func (s *Server) writeThermostat(thermostat map[string]interface{}, changes map[string]interface{}) (interface{}, int, string) {
+	for _, key := range sortedKeys(changes) {
+		if !thermostatFields[key] {
+			return nil, http.StatusForbidden, "No write permission(s) for field(s): " + key
+		}
+	}
+	updated := make(map[string]interface{})
+	for key, value := range thermostat {
+		updated[key] = value
+	}
+	if label, ok := changes["label"]; ok {
+		if jsonType(label) != "string" {
+			return nil, http.StatusBadRequest, "Invalid value for label"
+		}
+		updated["label"] = label
+	}
+	if scale, ok := changes["temperature_scale"]; ok {
+		if scale != "F" && scale != "C" {
+			return nil, http.StatusBadRequest, "Invalid value for temperature_scale"
+		}
+		updated["temperature_scale"] = scale
+	}
+	if duration, ok := changes["fan_timer_duration"]; ok {
+		value, isNumber := duration.(float64)
+		if !isNumber || !fanTimerDurations[value] {
+			return nil, http.StatusBadRequest, "Invalid value for fan_timer_duration"
+		}
+		updated["fan_timer_duration"] = duration
+	}
+	if active, ok := changes["fan_timer_active"]; ok {
+		if jsonType(active) != "bool" {
+			return nil, http.StatusBadRequest, "Invalid value for fan_timer_active"
+		}
+		if thermostat["has_fan"] != true {
+			return nil, http.StatusBadRequest, "Thermostat has no fan"
+		}
+		updated["fan_timer_active"] = active
+	}
+	if mode, ok := changes["hvac_mode"]; ok {
+		canHeat, canCool := thermostat["can_heat"] == true, thermostat["can_cool"] == true
+		supported := false
+		switch mode {
+		case "heat":
+			supported = canHeat
+		case "cool":
+			supported = canCool
+		case "heat-cool":
+			supported = canHeat && canCool
+		case "off", "eco":
+			supported = true
+		}
+		if !supported {
+			return nil, http.StatusBadRequest, "Invalid value for hvac_mode"
+		}
+		if mode != thermostat["hvac_mode"] && thermostat["hvac_mode"] != nil {
+			updated["previous_hvac_mode"] = thermostat["hvac_mode"]
+		}
+		updated["hvac_mode"] = mode
+	}
+	mode, _ := updated["hvac_mode"].(string)
+	targets := []string{}
+	for _, field := range []string{"target_temperature", "target_temperature_high", "target_temperature_low"} {
+		if _, f := changes[field+"_f"]; f {
+			targets = append(targets, field)
+		} else if _, c := changes[field+"_c"]; c {
+			targets = append(targets, field)
+		}
+	}
+	for _, field := range targets {
+		if field == "target_temperature" && mode != "heat" && mode != "cool" {
+			return nil, http.StatusBadRequest, "Cannot change target temperature while mode is " + mode
+		}
+		if field != "target_temperature" && mode != "heat-cool" {
+			return nil, http.StatusBadRequest, "Cannot change target temperature high or low while mode is " + mode
+		}
+		message := setTemperature(updated, field, changes)
+		if message != "" {
+			return nil, http.StatusBadRequest, message
+		}
+	}
+	if len(targets) > 0 && mode == "heat-cool" {
+		high, _ := updated["target_temperature_high_f"].(float64)
+		low, _ := updated["target_temperature_low_f"].(float64)
+		if high-low < 3 {
+			return nil, http.StatusBadRequest, "Target temperature high and low must be at least 3F apart"
+		}
+	}
+	for key, value := range updated {
+		thermostat[key] = value
+	}
+	return changes, http.StatusOK, ""
+}
+
+// setTemperature sets the farenheit and celcius fields of a temperature from whichever was written
+func setTemperature(thermostat map[string]interface{}, field string, changes map[string]interface{}) string {
+	var f, c float64
+	if value, ok := changes[field+"_f"]; ok {
+		number, isNumber := value.(float64)
+		if !isNumber || number != math.Trunc(number) {
+			return "Temperature F value must be a whole number"
+		}
+		if number < 50 || number > 90 {
+			return "Temperature F value is out of range: " + strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		f, c = number, math.Round((number-32)*5/9*2)/2
+	} else {
+		number, isNumber := changes[field+"_c"].(float64)
+		if !isNumber || number*2 != math.Trunc(number*2) {
+			return "Temperature C value must be in half degrees"
+		}
+		if number < 9 || number > 32 {
+			return "Temperature C value is out of range: " + strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		f, c = math.Round(number*9/5+32), number
+	}
+	if thermostat["is_locked"] == true {
+		low, _ := thermostat["locked_temp_min_f"].(float64)
+		high, _ := thermostat["locked_temp_max_f"].(float64)
+		if f < low || f > high {
+			return "Temperature is outside the locked range"
+		}
+	}
+	thermostat[field+"_f"] = f
+	thermostat[field+"_c"] = c
+	return ""
+}
+
+// writeETA sets or, when the window times are zero, cancels the ETA of a structure
+func writeETA(structure map[string]interface{}, changes map[string]interface{}) (interface{}, int, string) {
+	tripID, _ := changes["trip_id"].(string)
+	if tripID == "" {
+		return nil, http.StatusBadRequest, "Invalid value for trip_id"
+	}
+	begin, end := changes["estimated_arrival_window_begin"], changes["estimated_arrival_window_end"]
+	if begin == 0.0 && end == 0.0 {
+		delete(structure, "eta")
+		return changes, http.StatusOK, ""
+	}
+	beginString, _ := begin.(string)
+	endString, _ := end.(string)
+	beginTime, beginErr := time.Parse(time.RFC3339, beginString)
+	endTime, endErr := time.Parse(time.RFC3339, endString)
+	if beginErr != nil || endErr != nil {
+		return nil, http.StatusBadRequest, "Invalid value for estimated arrival window"
+	}
+	if endTime.Before(beginTime) {
+		return nil, http.StatusBadRequest, "Estimated arrival window end must be after begin"
+	}
+	structure["eta"] = changes
+	return changes, http.StatusOK, ""
+}
+
+// createWhere adds a where with the posted name to a structure
+func (s *Server) createWhere(structureID string, changes map[string]interface{}) (interface{}, int, string) {
+	structure, _ := lookup(s.tree, []string{"structures", structureID}).(map[string]interface{})
+	if structure == nil {
+		return nil, http.StatusNotFound, "Not found"
+	}
+	name, _ := changes["name"].(string)
+	if strings.TrimSpace(name) == "" {
+		return nil, http.StatusBadRequest, "Invalid value for name"
+	}
+	s.whereCount++
+	where := map[string]interface{}{
+		"where_id": "nesttest-where-" + strconv.Itoa(s.whereCount),
+		"name":     name,
+	}
+	set(structure, []string{"wheres", where["where_id"].(string)}, where)
+	return where, http.StatusOK, ""
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	}
+	return "other"
+}
+
+// sortedKeys returns the keys of an object in order
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,80 @@
+package nesttest
+
+import (
+	"testing"
+
+	"github.com/jsgoecke/nest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidation(t *testing.T) {
+	Convey("When writing to a thermostat", t, func() {
+		server := NewHome()
+		defer server.Close()
+
+		Convey("Read only fields should be rejected", func() {
+			status, body := request(server, "PUT", "/devices/thermostats/t1", `{"ambient_temperature_f":70}`)
+			So(status, ShouldEqual, 403)
+			So(body["error"], ShouldEqual, "No write permission(s) for field(s): ambient_temperature_f")
+		})
+		Convey("Temperatures should be converted to the other scale", func() {
+			status, _ := request(server, "PUT", "/devices/thermostats/t1", `{"target_temperature_c":22.5}`)
+			So(status, ShouldEqual, 200)
+			thermostat := &nest.Thermostat{}
+			server.Get("devices/thermostats/t1", thermostat)
+			So(thermostat.TargetTemperatureF, ShouldEqual, 73)
+		})
+		Convey("Temperatures out of range or in partial degrees should be rejected", func() {
+			status, body := request(server, "PUT", "/devices/thermostats/t1", `{"target_temperature_f":95}`)
+			So(status, ShouldEqual, 400)
+			So(body["error"], ShouldEqual, "Temperature F value is out of range: 95")
+			_, body = request(server, "PUT", "/devices/thermostats/t1", `{"target_temperature_c":21.2}`)
+			So(body["error"], ShouldEqual, "Temperature C value must be in half degrees")
+		})
+		Convey("Target temperatures should follow the hvac mode", func() {
+			_, body := request(server, "PUT", "/devices/thermostats/t1", `{"target_temperature_high_f":75,"target_temperature_low_f":65}`)
+			So(body["error"], ShouldEqual, "Cannot change target temperature high or low while mode is heat")
+			status, _ := request(server, "PUT", "/devices/thermostats/t1", `{"hvac_mode":"heat-cool","target_temperature_high_f":75,"target_temperature_low_f":65}`)
+			So(status, ShouldEqual, 200)
+			_, body = request(server, "PUT", "/devices/thermostats/t1", `{"target_temperature_high_f":70,"target_temperature_low_f":69}`)
+			So(body["error"], ShouldEqual, "Target temperature high and low must be at least 3F apart")
+			_, body = request(server, "PUT", "/devices/thermostats/t1", `{"target_temperature_f":70}`)
+			So(body["error"], ShouldEqual, "Cannot change target temperature while mode is heat-cool")
+		})
+		Convey("A locked thermostat should only accept temperatures in its range", func() {
+			server.Set("devices/thermostats/t1/is_locked", true)
+			server.Set("devices/thermostats/t1/locked_temp_min_f", 65)
+			server.Set("devices/thermostats/t1/locked_temp_max_f", 72)
+			_, body := request(server, "PUT", "/devices/thermostats/t1", `{"target_temperature_f":75}`)
+			So(body["error"], ShouldEqual, "Temperature is outside the locked range")
+		})
+		Convey("Modes the system cannot run should be rejected", func() {
+			server.Set("devices/thermostats/t1/can_cool", false)
+			_, body := request(server, "PUT", "/devices/thermostats/t1", `{"hvac_mode":"cool"}`)
+			So(body["error"], ShouldEqual, "Invalid value for hvac_mode")
+		})
+		Convey("A failed write should leave the thermostat unchanged", func() {
+			request(server, "PUT", "/devices/thermostats/t1", `{"hvac_mode":"cool","target_temperature_f":99}`)
+			thermostat := &nest.Thermostat{}
+			server.Get("devices/thermostats/t1", thermostat)
+			So(thermostat.HvacMode, ShouldEqual, nest.Heat)
+		})
+		Convey("The fan timer should need a fan", func() {
+			server.Set("devices/thermostats/t1/has_fan", false)
+			_, body := request(server, "PUT", "/devices/thermostats/t1", `{"fan_timer_active":true}`)
+			So(body["error"], ShouldEqual, "Thermostat has no fan")
+		})
+	})
+
+	Convey("When writing to a structure", t, func() {
+		server := NewHome()
+		defer server.Close()
+		status, body := request(server, "PUT", "/structures/s1", `{"away":"auto-away"}`)
+		So(status, ShouldEqual, 400)
+		So(body["error"], ShouldEqual, "Invalid value for away")
+		status, _ = request(server, "PUT", "/structures/s2", `{"away":"away"}`)
+		So(status, ShouldEqual, 404)
+		_, body = request(server, "PUT", "/structures/s1/eta", `{"trip_id":"trip","estimated_arrival_window_begin":"2016-03-01T10:00:00Z","estimated_arrival_window_end":"2016-03-01T09:00:00Z"}`)
+		So(body["error"], ShouldEqual, "Estimated arrival window end must be after begin")
+	})
+}
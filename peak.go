@@ -0,0 +1,189 @@
+package nest
+
+import (
+	"sort"
+	"time"
+)
+
+// PeakPeriodEventType represents a change in a structure's Rush Hour Rewards peak period
+type PeakPeriodEventType string
+
+const (
+	// PeakPeriodScheduled is emitted when a structure reports a new upcoming peak period
+	PeakPeriodScheduled PeakPeriodEventType = "scheduled"
+	// PeakPeriodStarted is emitted when a peak period begins
+	PeakPeriodStarted PeakPeriodEventType = "started"
+	// PeakPeriodEnded is emitted when a peak period ends or is withdrawn while running
+	PeakPeriodEnded PeakPeriodEventType = "ended"
+)
+
+// PeakPeriodEvent represents the scheduling, start or end of a peak period
+type PeakPeriodEvent struct {
+	Type        PeakPeriodEventType
+	StructureID string
+	Name        string
+	Start       time.Time
+	End         time.Time
+	Time        time.Time
+}
+
+// PeakPeriodWatcher tracks the peak periods of structures and reports when they are scheduled, start and end
+type PeakPeriodWatcher struct {
+	periods map[string]*peakPeriod
+}
+
+// peakPeriod is the last known peak period of one structure
+type peakPeriod struct {
+	name    string
+	start   time.Time
+	end     time.Time
+	started bool
+	ended   bool
+}
+
+// peakPeriodTick is how often PeakPeriodsStream checks whether a period began or ended
+const peakPeriodTick = time.Minute
+
+/*
+InPeakPeriod returns true when now falls within the structure's peak period
+https://developer.nest.com/documentation/api#peak_period_start_time
+
+	if structure.InPeakPeriod(time.Now()) {
+		fmt.Println("Rush Hour Rewards in progress")
+	}
+*/
+func (s *Structure) InPeakPeriod(now time.Time) bool {
+	if s.PeakPeriodStartTime.IsZero() || s.PeakPeriodEndTime.IsZero() {
+		return false
+	}
+	return !now.Before(s.PeakPeriodStartTime) && now.Before(s.PeakPeriodEndTime)
+}
+
+/*
+NextPeakPeriod returns the current or upcoming peak period, ok is false when there is none
+
+	start, end, ok := structure.NextPeakPeriod(time.Now())
+*/
+func (s *Structure) NextPeakPeriod(now time.Time) (start time.Time, end time.Time, ok bool) {
+	if s.PeakPeriodStartTime.IsZero() || !s.PeakPeriodEndTime.After(now) {
+		return start, end, false
+	}
+	return s.PeakPeriodStartTime, s.PeakPeriodEndTime, true
+}
+
+/*
+NewPeakPeriodWatcher creates a new PeakPeriodWatcher
+
+	watcher := nest.NewPeakPeriodWatcher()
+	events := watcher.Update(structures, time.Now())
+*/
+func NewPeakPeriodWatcher() *PeakPeriodWatcher {
+	return &PeakPeriodWatcher{periods: make(map[string]*peakPeriod)}
+}
+
+/*
+PeakPeriodsStream emits peak period events from the Nest structures REST streaming API
+
+	client.PeakPeriodsStream(func(event *nest.PeakPeriodEvent, err error) {
+		fmt.Println(event.Type, event.Name, event.Start)
+	})
+*/
+func (c *Client) PeakPeriodsStream(callback func(event *PeakPeriodEvent, err error)) {
+	type update struct {
+		structures map[string]*Structure
+		err        error
+	}
+	updates := make(chan update)
+	go c.StructuresStream(func(structures map[string]*Structure, err error) {
+		updates <- update{structures, err}
+	})
+	watcher := NewPeakPeriodWatcher()
+	ticker := time.NewTicker(peakPeriodTick)
+	defer ticker.Stop()
+	for {
+		var events []*PeakPeriodEvent
+		select {
+		case u := <-updates:
+			if u.err != nil {
+				callback(nil, u.err)
+				continue
+			}
+			events = watcher.Update(u.structures, time.Now())
+		case now := <-ticker.C:
+			events = watcher.Tick(now)
+		}
+		for _, event := range events {
+			callback(event, nil)
+		}
+	}
+}
+
+// Update records the peak periods of structures and returns the resulting events
+func (w *PeakPeriodWatcher) Update(structures map[string]*Structure, now time.Time) []*PeakPeriodEvent {
+	events := []*PeakPeriodEvent{}
+	for _, id := range sortedStructureIDs(structures) {
+		structure := structures[id]
+		period, ok := w.periods[id]
+		start, end := structure.PeakPeriodStartTime, structure.PeakPeriodEndTime
+		if ok && period.start.Equal(start) && period.end.Equal(end) {
+			continue
+		}
+		if ok && period.started && !period.ended {
+			events = append(events, period.event(PeakPeriodEnded, id, now))
+		}
+		if start.IsZero() || end.IsZero() || !end.After(now) {
+			w.periods[id] = &peakPeriod{name: structure.Name, start: start, end: end, started: true, ended: true}
+			continue
+		}
+		period = &peakPeriod{name: structure.Name, start: start, end: end}
+		w.periods[id] = period
+		if now.Before(start) {
+			events = append(events, period.event(PeakPeriodScheduled, id, now))
+		}
+	}
+	return append(events, w.Tick(now)...)
+}
+
+// Tick returns the events for peak periods that started or ended by now
+func (w *PeakPeriodWatcher) Tick(now time.Time) []*PeakPeriodEvent {
+	events := []*PeakPeriodEvent{}
+	ids := make([]string, 0, len(w.periods))
+	for id := range w.periods {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		period := w.periods[id]
+		if !period.started && !now.Before(period.start) {
+			period.started = true
+			events = append(events, period.event(PeakPeriodStarted, id, now))
+		}
+		if period.started && !period.ended && !now.Before(period.end) {
+			period.ended = true
+			events = append(events, period.event(PeakPeriodEnded, id, now))
+		}
+	}
+	return events
+}
+
+// event creates an event for the peak period
+func (p *peakPeriod) event(eventType PeakPeriodEventType, structureID string, now time.Time) *PeakPeriodEvent {
+	return &PeakPeriodEvent{
+		Type:        eventType,
+		StructureID: structureID,
+		Name:        p.name,
+		Start:       p.start,
+		End:         p.end,
+		Time:        now,
+	}
+}
+
+// sortedStructureIDs returns the keys of structures in order
+func sortedStructureIDs(structures map[string]*Structure) []string {
+	ids := make([]string, 0, len(structures))
+	for id := range structures {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,78 @@
+package nest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestPeakPeriod(t *testing.T) {
+	start := time.Date(2014, 7, 4, 14, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+
+	Convey("Given a structure with a peak period", t, func() {
+		structure := &Structure{StructureID: "s1234", Name: "Home", PeakPeriodStartTime: start, PeakPeriodEndTime: end}
+
+		Convey("We should know when we are in it", func() {
+			So(structure.InPeakPeriod(start.Add(-time.Minute)), ShouldBeFalse)
+			So(structure.InPeakPeriod(start), ShouldBeTrue)
+			So(structure.InPeakPeriod(end), ShouldBeFalse)
+			So((&Structure{}).InPeakPeriod(start), ShouldBeFalse)
+		})
+
+		Convey("We should get the next one until it ends", func() {
+			next, last, ok := structure.NextPeakPeriod(start.Add(-time.Hour))
+			So(ok, ShouldBeTrue)
+			So(next, ShouldEqual, start)
+			So(last, ShouldEqual, end)
+			_, _, ok = structure.NextPeakPeriod(start.Add(time.Hour))
+			So(ok, ShouldBeTrue)
+			_, _, ok = structure.NextPeakPeriod(end)
+			So(ok, ShouldBeFalse)
+		})
+	})
+
+	Convey("Given a peak period watcher", t, func() {
+		watcher := NewPeakPeriodWatcher()
+		structure := &Structure{StructureID: "s1234", Name: "Home", PeakPeriodStartTime: start, PeakPeriodEndTime: end}
+		structures := map[string]*Structure{"s1234": structure}
+
+		Convey("A new period should be scheduled, started and ended once", func() {
+			events := watcher.Update(structures, start.Add(-time.Hour))
+			So(len(events), ShouldEqual, 1)
+			So(events[0].Type, ShouldEqual, PeakPeriodScheduled)
+			So(events[0].Name, ShouldEqual, "Home")
+			So(watcher.Update(structures, start.Add(-time.Minute)), ShouldBeEmpty)
+
+			events = watcher.Tick(start)
+			So(len(events), ShouldEqual, 1)
+			So(events[0].Type, ShouldEqual, PeakPeriodStarted)
+			So(watcher.Tick(start.Add(time.Minute)), ShouldBeEmpty)
+
+			events = watcher.Tick(end)
+			So(len(events), ShouldEqual, 1)
+			So(events[0].Type, ShouldEqual, PeakPeriodEnded)
+			So(watcher.Update(structures, end.Add(time.Minute)), ShouldBeEmpty)
+		})
+
+		Convey("A period already running when first seen should start", func() {
+			events := watcher.Update(structures, start.Add(time.Minute))
+			So(len(events), ShouldEqual, 1)
+			So(events[0].Type, ShouldEqual, PeakPeriodStarted)
+		})
+
+		Convey("A period already over when first seen should be ignored", func() {
+			So(watcher.Update(structures, end.Add(time.Hour)), ShouldBeEmpty)
+			So(watcher.Tick(end.Add(2*time.Hour)), ShouldBeEmpty)
+		})
+
+		Convey("A running period that is withdrawn should end", func() {
+			watcher.Update(structures, start.Add(time.Minute))
+			structure.PeakPeriodStartTime = time.Time{}
+			structure.PeakPeriodEndTime = time.Time{}
+			events := watcher.Update(structures, start.Add(2*time.Minute))
+			So(len(events), ShouldEqual, 1)
+			So(events[0].Type, ShouldEqual, PeakPeriodEnded)
+		})
+	})
+}
@@ -0,0 +1,333 @@
+package nest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Redacted replaces tokens, codes and secrets in recorded traffic
+	Redacted = "REDACTED"
+	// CassetteResponse is the type of a cassette entry holding a request and its response
+	CassetteResponse = "response"
+	// CassetteStreamLine is the type of a cassette entry holding one line read from a stream
+	CassetteStreamLine = "stream"
+)
+
+// redactedParams are the query parameters whose values are never recorded
+var redactedParams = []string{"auth", "code", "client_secret"}
+
+// accessTokenPattern finds access tokens in token responses
+var accessTokenPattern = regexp.MustCompile(`"access_token"\s*:\s*"([^"]+)"`)
+
+// CassetteEntry represents one line of a cassette, either a response or a line read from a stream
+type CassetteEntry struct {
+	Type         string `json:"type"`
+	ID           int    `json:"id"`
+	Method       string `json:"method,omitempty"`
+	URL          string `json:"url,omitempty"`
+	RequestBody  string `json:"request_body,omitempty"`
+	StatusCode   int    `json:"status_code,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	Location     string `json:"location,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+	Stream       bool   `json:"stream,omitempty"`
+	Line         string `json:"line,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that writes the traffic it carries to a cassette as JSON lines
+type Recorder struct {
+	Transport http.RoundTripper
+	writer    io.Writer
+	mu        sync.Mutex
+	count     int
+	secrets   map[string]bool
+}
+
+// Replayer is an http.RoundTripper that answers requests from a cassette written by a Recorder
+type Replayer struct {
+	mu      sync.Mutex
+	entries []*CassetteEntry
+	lines   map[int][]string
+	used    map[int]bool
+}
+
+/*
+Record makes the client write all of its traffic to w, with tokens, codes and secrets redacted
+
+	cassette, _ := os.Create("nest.cassette")
+	client.Record(cassette)
+*/
+func (c *Client) Record(w io.Writer) {
+	c.HTTPClient = &http.Client{Transport: NewRecorder(w, nil)}
+}
+
+/*
+Replay makes the client answer all of its requests from a cassette instead of the Nest API
+
+	cassette, _ := os.Open("nest.cassette")
+	client.Replay(cassette)
+*/
+func (c *Client) Replay(r io.Reader) error {
+	replayer, err := NewReplayer(r)
+	if err != nil {
+		return err
+	}
+	c.HTTPClient = &http.Client{Transport: replayer}
+	return nil
+}
+
+/*
+NewRecorder creates a Recorder writing to w, sending requests with transport or http.DefaultTransport if nil
+
+	client.HTTPClient = &http.Client{Transport: nest.NewRecorder(cassette, nil)}
+*/
+func NewRecorder(w io.Writer, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{
+		Transport: transport,
+		writer:    w,
+		secrets:   make(map[string]bool),
+	}
+}
+
+// RoundTrip sends the request and records it along with the response. Streams are recorded line by line as they are read.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		requestBody, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	}
+	resp, err := r.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.count++
+	id := r.count
+	r.mu.Unlock()
+	entry := &CassetteEntry{
+		Type:        CassetteResponse,
+		ID:          id,
+		Method:      req.Method,
+		URL:         r.redactURL(req.URL.String()),
+		RequestBody: r.redact(string(requestBody)),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Location:    r.redactURL(resp.Header.Get("Location")),
+	}
+	if strings.HasPrefix(entry.ContentType, "text/event-stream") {
+		entry.Stream = true
+		r.write(entry)
+		resp.Body = &recordingBody{body: resp.Body, recorder: r, id: id}
+		return resp, nil
+	}
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+	for _, match := range accessTokenPattern.FindAllStringSubmatch(string(responseBody), -1) {
+		r.mu.Lock()
+		r.secrets[match[1]] = true
+		r.mu.Unlock()
+	}
+	entry.ResponseBody = r.redact(string(responseBody))
+	r.write(entry)
+	return resp, nil
+}
+
+// write appends an entry to the cassette
+func (r *Recorder) write(entry *CassetteEntry) {
+	data, _ := json.Marshal(entry)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writer.Write(append(data, '\n'))
+}
+
+// redactURL blanks out the redacted query parameters, remembering their values so they can be redacted elsewhere
+func (r *Recorder) redactURL(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return r.redact(rawURL)
+	}
+	query := parsed.Query()
+	r.mu.Lock()
+	for _, param := range redactedParams {
+		if value := query.Get(param); value != "" {
+			r.secrets[value] = true
+		}
+	}
+	r.mu.Unlock()
+	parsed.RawQuery = redactQuery(parsed.RawQuery)
+	return r.redact(parsed.String())
+}
+
+// redact replaces every secret seen so far in text, longest first so a secret containing another is replaced whole
+func (r *Recorder) redact(text string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	secrets := make([]string, 0, len(r.secrets))
+	for secret := range r.secrets {
+		secrets = append(secrets, secret)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		if len(secrets[i]) != len(secrets[j]) {
+			return len(secrets[i]) > len(secrets[j])
+		}
+		return secrets[i] < secrets[j]
+	})
+	for _, secret := range secrets {
+		text = strings.Replace(text, secret, Redacted, -1)
+	}
+	return text
+}
+
+// recordingBody records each line of a stream as it is read
+type recordingBody struct {
+	body     io.ReadCloser
+	recorder *Recorder
+	id       int
+	partial  []byte
+}
+
+// Read reads from the stream, recording every complete line and whatever is left of the last one at the end
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.partial = append(b.partial, p[:n]...)
+	for {
+		end := bytes.IndexByte(b.partial, '\n')
+		if end < 0 {
+			break
+		}
+		b.writeLine(b.partial[:end])
+		b.partial = b.partial[end+1:]
+	}
+	if err == io.EOF && len(b.partial) > 0 {
+		b.writeLine(b.partial)
+		b.partial = nil
+	}
+	return n, err
+}
+
+// writeLine records a line of the stream
+func (b *recordingBody) writeLine(line []byte) {
+	b.recorder.write(&CassetteEntry{
+		Type: CassetteStreamLine,
+		ID:   b.id,
+		Line: b.recorder.redact(string(line)),
+	})
+}
+
+// Close closes the stream
+func (b *recordingBody) Close() error {
+	return b.body.Close()
+}
+
+/*
+NewReplayer creates a Replayer from a cassette
+
+	replayer, err := nest.NewReplayer(cassette)
+	client.HTTPClient = &http.Client{Transport: replayer}
+*/
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	replayer := &Replayer{
+		lines: make(map[int][]string),
+		used:  make(map[int]bool),
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		entry := &CassetteEntry{}
+		err := json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil {
+			return nil, err
+		}
+		switch entry.Type {
+		case CassetteResponse:
+			replayer.entries = append(replayer.entries, entry)
+		case CassetteStreamLine:
+			replayer.lines[entry.ID] = append(replayer.lines[entry.ID], entry.Line)
+		}
+	}
+	return replayer, scanner.Err()
+}
+
+// RoundTrip answers the request with the first unused recorded response to the same method and URL
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	requestURL, _ := url.Parse(req.URL.String())
+	requestURL.RawQuery = redactQuery(requestURL.RawQuery)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entry := range r.entries {
+		if r.used[entry.ID] || entry.Method != req.Method || entry.URL != requestURL.String() {
+			continue
+		}
+		r.used[entry.ID] = true
+		body := entry.ResponseBody
+		if entry.Stream {
+			body = ""
+			for _, line := range r.lines[entry.ID] {
+				body += line + "\n"
+			}
+		}
+		resp := &http.Response{
+			Status:        http.StatusText(entry.StatusCode),
+			StatusCode:    entry.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        make(http.Header),
+			Body:          ioutil.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}
+		if entry.ContentType != "" {
+			resp.Header.Set("Content-Type", entry.ContentType)
+		}
+		if entry.Location != "" {
+			resp.Header.Set("Location", entry.Location)
+		}
+		return resp, nil
+	}
+	return nil, errors.New("nest: no recorded response left for " + req.Method + " " + requestURL.String())
+}
+
+// redactQuery blanks out the redacted parameters of a raw query, keeping the order of the parameters
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		for _, name := range redactedParams {
+			if strings.HasPrefix(param, name+"=") {
+				params[i] = name + "=" + Redacted
+			}
+		}
+	}
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,93 @@
+package nest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecordReplay(t *testing.T) {
+	Convey("When recording traffic with the Nest API", t, func() {
+		cassette := &bytes.Buffer{}
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.AccessTokenURL = ts.URL
+		client.APIURL = ts.URL
+		client.Record(cassette)
+		So(client.Authorize(), ShouldBeNil)
+		devices, apiErr := client.Devices()
+		So(apiErr, ShouldBeNil)
+		So(devices.Thermostats["z1234"].SetLabel("Upstairs"), ShouldBeNil)
+		streamed := []*Devices{}
+		client.streamDevices(func(devices *Devices, err error) {
+			streamed = append(streamed, devices)
+		})
+		So(len(streamed), ShouldEqual, 2)
+
+		Convey("Tokens, codes and secrets should be redacted", func() {
+			recorded := cassette.String()
+			So(recorded, ShouldNotContainSubstring, Token)
+			So(recorded, ShouldNotContainSubstring, AuthorizationCode)
+			So(recorded, ShouldNotContainSubstring, ClientSecret)
+			So(recorded, ShouldContainSubstring, "auth="+Redacted)
+			So(strings.Count(recorded, `"type":"stream"`), ShouldBeGreaterThan, 0)
+		})
+		Convey("Replaying the cassette should give the same results without the Nest API", func() {
+			replay := New(ClientID, State, ClientSecret, AuthorizationCode)
+			replay.AccessTokenURL = ts.URL
+			replay.APIURL = ts.URL
+			So(replay.Replay(bytes.NewReader(cassette.Bytes())), ShouldBeNil)
+			So(replay.Authorize(), ShouldBeNil)
+			So(replay.Token, ShouldEqual, Redacted)
+			replayed, apiErr := replay.Devices()
+			So(apiErr, ShouldBeNil)
+			So(replay.RedirectURL, ShouldEqual, client.RedirectURL)
+			So(replayed.Thermostats["z1234"].Name, ShouldEqual, devices.Thermostats["z1234"].Name)
+			So(replayed.Thermostats["z1234"].SetLabel("Upstairs"), ShouldBeNil)
+			So(replayed.Thermostats["z1234"].Label, ShouldEqual, "Upstairs")
+			replayedStream := []*Devices{}
+			replay.streamDevices(func(devices *Devices, err error) {
+				replayedStream = append(replayedStream, devices)
+			})
+			So(replayedStream, ShouldHaveLength, 2)
+			So(replayedStream[1].SmokeCoAlarms["a3455"].CoAlarmState, ShouldEqual, streamed[1].SmokeCoAlarms["a3455"].CoAlarmState)
+
+			Convey("Requests not in the cassette should fail", func() {
+				_, apiErr := replay.Devices()
+				So(apiErr.Description, ShouldContainSubstring, "no recorded response left")
+			})
+		})
+		Convey("A broken cassette should be reported", func() {
+			So(client.Replay(strings.NewReader("{not json")), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRecorderRedact(t *testing.T) {
+	Convey("A secret containing another should be redacted whole", t, func() {
+		recorder := NewRecorder(&bytes.Buffer{}, nil)
+		recorder.secrets["c.abc"] = true
+		recorder.secrets["c.abcdef"] = true
+		for i := 0; i < 20; i++ {
+			So(recorder.redact("auth=c.abcdef"), ShouldEqual, "auth="+Redacted)
+		}
+	})
+}
+
+func TestRecordingBody(t *testing.T) {
+	Convey("A last stream line without a newline should still be recorded", t, func() {
+		cassette := &bytes.Buffer{}
+		body := &recordingBody{
+			body:     ioutil.NopCloser(strings.NewReader("event: put\ndata: null")),
+			recorder: NewRecorder(cassette, nil),
+			id:       1,
+		}
+		data, err := ioutil.ReadAll(body)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "event: put\ndata: null")
+		So(strings.Count(cassette.String(), `"type":"stream"`), ShouldEqual, 2)
+		So(cassette.String(), ShouldContainSubstring, `"line":"data: null"`)
+	})
+}
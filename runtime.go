@@ -0,0 +1,292 @@
+package nest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultShortCycle is the run time under which a heating or cooling cycle counts as a short cycle
+	DefaultShortCycle = 10 * time.Minute
+)
+
+// EnergyRate is how much energy a system uses per hour of running, in a unit such as "kWh" or "therm"
+type EnergyRate struct {
+	PerHour float64
+	Unit    string
+}
+
+// RuntimeRates are the energy rates of a thermostat's heating, cooling and fan. Any may be nil.
+type RuntimeRates struct {
+	Heating *EnergyRate
+	Cooling *EnergyRate
+	Fan     *EnergyRate
+}
+
+/*
+RuntimeUsage is how long a thermostat spent heating, cooling and running its fan timer over an hour
+or a day. Cycles count the heating and cooling runs that started, and ShortCycles the runs shorter
+than the tracker's ShortCycle that ended. Energy holds the energy used by unit when rates are set.
+Usage worked out from recorded history, which knows no cycles or rates, only has the durations.
+*/
+type RuntimeUsage struct {
+	Heating       time.Duration
+	Cooling       time.Duration
+	Fan           time.Duration
+	HeatingCycles int
+	CoolingCycles int
+	ShortCycles   int
+	Energy        map[string]float64
+}
+
+/*
+RuntimeTracker adds up how long each thermostat spends heating, cooling and running its fan timer
+from devices payloads, by hour. Time between two updates of a thermostat is counted in the state of
+the first one, unless the thermostat was offline or the stream was disconnected in between, in which
+case what happened is unknown and nothing is counted. The stream only sends changes, so updates may
+be far apart; set MaxGap to also skip time between updates further apart than that.
+*/
+type RuntimeTracker struct {
+	MaxGap      time.Duration
+	ShortCycle  time.Duration
+	Location    *time.Location
+	Rates       map[string]*RuntimeRates
+	mu          sync.Mutex
+	thermostats map[string]*runtimeStatus
+}
+
+// runtimeStatus is the last known state of one thermostat and its usage by the Unix time its hours start
+type runtimeStatus struct {
+	known      bool
+	state      HvacState
+	fan        bool
+	since      time.Time
+	cycleStart time.Time
+	hours      map[int64]*RuntimeUsage
+}
+
+/*
+NewRuntimeTracker creates a new RuntimeTracker counting hours and days in the local time zone.
+The first update of a thermostat only records its state, so a restarted tracker never counts
+time it did not see.
+
+	tracker := nest.NewRuntimeTracker()
+	tracker.Rates[deviceID] = &nest.RuntimeRates{
+		Heating: &nest.EnergyRate{PerHour: 0.8, Unit: "therm"},
+		Cooling: &nest.EnergyRate{PerHour: 3.5, Unit: "kWh"},
+	}
+	tracker.Update(devices, time.Now())
+*/
+func NewRuntimeTracker() *RuntimeTracker {
+	return &RuntimeTracker{
+		ShortCycle:  DefaultShortCycle,
+		Location:    time.Local,
+		Rates:       make(map[string]*RuntimeRates),
+		thermostats: make(map[string]*runtimeStatus),
+	}
+}
+
+/*
+TrackRuntime feeds the tracker from the Nest devices REST streaming API until ctx is done, telling the
+tracker it was disconnected whenever the stream fails, is refused or ends. The stream is reconnected
+like WatchDevices does.
+
+	go client.TrackRuntime(ctx, tracker, 5*time.Second, func(err error) {
+		fmt.Println(err)
+	})
+*/
+func (c *Client) TrackRuntime(ctx context.Context, tracker *RuntimeTracker, retryDelay time.Duration, callback func(err error)) {
+	c.WatchDevices(ctx, retryDelay, func(devices *Devices, err error) {
+		if err != nil {
+			tracker.Disconnected(time.Now())
+			if callback != nil {
+				callback(err)
+			}
+			return
+		}
+		tracker.Update(devices, time.Now())
+	})
+}
+
+// Update counts the time since each thermostat's last update and records its new state
+func (r *RuntimeTracker) Update(devices *Devices, now time.Time) {
+	if devices == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(devices.Thermostats))
+	for id := range devices.Thermostats {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		r.observe(id, devices.Thermostats[id], now)
+	}
+}
+
+// Disconnected counts every thermostat's time up to now and forgets their states, so the time until their next update is not counted
+func (r *RuntimeTracker) Disconnected(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, status := range r.thermostats {
+		if status.known && r.counts(status, now) {
+			r.count(status, now)
+		}
+		status.known = false
+	}
+}
+
+/*
+Hourly returns the thermostat's usage during the hour starting at or containing the time
+
+	usage := tracker.Hourly(deviceID, time.Now())
+*/
+func (r *RuntimeTracker) Hourly(deviceID string, hour time.Time) *RuntimeUsage {
+	start := r.hourStart(hour)
+	return r.usage(deviceID, start, start.Add(time.Hour))
+}
+
+/*
+Daily returns the thermostat's usage during the day containing the time, in the tracker's Location
+
+	usage := tracker.Daily(deviceID, time.Now())
+	fmt.Println(usage.Heating, usage.ShortCycles, usage.Energy["kWh"])
+*/
+func (r *RuntimeTracker) Daily(deviceID string, day time.Time) *RuntimeUsage {
+	day = day.In(r.Location)
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, r.Location)
+	return r.usage(deviceID, start, start.AddDate(0, 0, 1))
+}
+
+// Prune forgets the usage of hours starting before the time
+func (r *RuntimeTracker) Prune(before time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, status := range r.thermostats {
+		for hour := range status.hours {
+			if hour < before.Unix() {
+				delete(status.hours, hour)
+			}
+		}
+	}
+}
+
+// observe counts the time since the thermostat's last update and records its new state
+func (r *RuntimeTracker) observe(id string, t *Thermostat, now time.Time) {
+	status, ok := r.thermostats[id]
+	if !ok {
+		status = &runtimeStatus{hours: make(map[int64]*RuntimeUsage)}
+		r.thermostats[id] = status
+	}
+	if status.known && now.Before(status.since) {
+		return
+	}
+	continuous := status.known && r.counts(status, now)
+	if continuous {
+		r.count(status, now)
+	}
+	if t.HvacState != status.state || !continuous {
+		if continuous && running(status.state) && !status.cycleStart.IsZero() && now.Sub(status.cycleStart) < r.ShortCycle {
+			r.hour(status, now).ShortCycles++
+		}
+		status.cycleStart = time.Time{}
+		if continuous && running(t.HvacState) {
+			status.cycleStart = now
+			if t.HvacState == Heating {
+				r.hour(status, now).HeatingCycles++
+			} else {
+				r.hour(status, now).CoolingCycles++
+			}
+		}
+	}
+	status.known = t.IsOnline
+	status.state = t.HvacState
+	status.fan = t.FanTimerActive
+	status.since = now
+}
+
+// counts returns whether the time from the thermostat's last update until now can be counted
+func (r *RuntimeTracker) counts(status *runtimeStatus, now time.Time) bool {
+	return r.MaxGap <= 0 || now.Sub(status.since) <= r.MaxGap
+}
+
+// count adds the time since the thermostat's last update to the hours it spans
+func (r *RuntimeTracker) count(status *runtimeStatus, now time.Time) {
+	for start := status.since; start.Before(now); {
+		end := r.hourStart(start).Add(time.Hour)
+		if end.After(now) {
+			end = now
+		}
+		usage := r.hour(status, start)
+		switch status.state {
+		case Heating:
+			usage.Heating += end.Sub(start)
+		case Cooling:
+			usage.Cooling += end.Sub(start)
+		}
+		if status.fan {
+			usage.Fan += end.Sub(start)
+		}
+		start = end
+	}
+	status.since = now
+}
+
+// hour returns the thermostat's usage during the hour containing the time
+func (r *RuntimeTracker) hour(status *runtimeStatus, t time.Time) *RuntimeUsage {
+	key := r.hourStart(t).Unix()
+	usage, ok := status.hours[key]
+	if !ok {
+		usage = &RuntimeUsage{}
+		status.hours[key] = usage
+	}
+	return usage
+}
+
+// hourStart returns the start of the hour containing the time, in the tracker's Location
+func (r *RuntimeTracker) hourStart(t time.Time) time.Time {
+	t = t.In(r.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, r.Location)
+}
+
+// usage adds up the thermostat's usage during the hours starting from from until to, applying its rates
+func (r *RuntimeTracker) usage(deviceID string, from time.Time, to time.Time) *RuntimeUsage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := &RuntimeUsage{Energy: make(map[string]float64)}
+	if status, ok := r.thermostats[deviceID]; ok {
+		for hour, usage := range status.hours {
+			if hour < from.Unix() || hour >= to.Unix() {
+				continue
+			}
+			total.Heating += usage.Heating
+			total.Cooling += usage.Cooling
+			total.Fan += usage.Fan
+			total.HeatingCycles += usage.HeatingCycles
+			total.CoolingCycles += usage.CoolingCycles
+			total.ShortCycles += usage.ShortCycles
+		}
+	}
+	if rates, ok := r.Rates[deviceID]; ok && rates != nil {
+		addEnergy(total.Energy, rates.Heating, total.Heating)
+		addEnergy(total.Energy, rates.Cooling, total.Cooling)
+		addEnergy(total.Energy, rates.Fan, total.Fan)
+	}
+	return total
+}
+
+// addEnergy adds the energy used running for the duration at the rate
+func addEnergy(energy map[string]float64, rate *EnergyRate, d time.Duration) {
+	if rate == nil || d == 0 {
+		return
+	}
+	energy[rate.Unit] += rate.PerHour * d.Hours()
+}
+
+// running returns whether the state is heating or cooling
+func running(state HvacState) bool {
+	return state == Heating || state == Cooling
+}
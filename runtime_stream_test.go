@@ -0,0 +1,71 @@
+package nest_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsgoecke/nest"
+	"github.com/jsgoecke/nest/nesttest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// waitUntil polls the condition for a few seconds, reporting whether it became true
+func waitUntil(condition func() bool) bool {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestTrackRuntime(t *testing.T) {
+	Convey("When tracking a heating thermostat whose stream is cut off", t, func() {
+		server := nesttest.NewServer()
+		defer server.Close()
+		server.AddThermostat(&nest.Thermostat{DeviceID: "t1", IsOnline: true, HvacMode: nest.Heat, HvacState: nest.Heating})
+		tracker := nest.NewRuntimeTracker()
+		tracker.Location = time.UTC
+		mu := sync.Mutex{}
+		errs := []error{}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go server.Client().TrackRuntime(ctx, tracker, 20*time.Millisecond, func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		})
+		So(waitUntil(func() bool { return server.OpenStreams() == 1 }), ShouldBeTrue)
+		time.Sleep(50 * time.Millisecond)
+
+		Convey("Time while the token was revoked should not be counted", func() {
+			server.RevokeAuth()
+			time.Sleep(500 * time.Millisecond)
+			resp, err := http.Post(server.AccessTokenURL+"?grant_type=authorization_code&code="+server.AuthorizationCode, "", nil)
+			So(err, ShouldBeNil)
+			resp.Body.Close()
+			So(waitUntil(func() bool { return server.OpenStreams() == 1 }), ShouldBeTrue)
+			time.Sleep(50 * time.Millisecond)
+			server.Set("/devices/thermostats/t1/hvac_state", "off")
+			So(waitUntil(func() bool { return tracker.Daily("t1", time.Now()).Heating > 0 }), ShouldBeTrue)
+			So(tracker.Daily("t1", time.Now()).Heating, ShouldBeLessThan, 400*time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			So(errs[0], ShouldEqual, nest.ErrStreamEnded)
+			So(errs[1].(*nest.StreamError).StatusCode, ShouldEqual, http.StatusUnauthorized)
+		})
+		Convey("Time while the stream was dropped should not be counted", func() {
+			server.CloseStreams()
+			So(waitUntil(func() bool { return tracker.Daily("t1", time.Now()).Heating > 0 }), ShouldBeTrue)
+			heating := tracker.Daily("t1", time.Now()).Heating
+			So(heating, ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+			time.Sleep(100 * time.Millisecond)
+			So(tracker.Daily("t1", time.Now()).Heating, ShouldEqual, heating)
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package nest
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestRuntimeTracker(t *testing.T) {
+	Convey("Given a runtime tracker fed by a fake stream", t, func() {
+		tracker := NewRuntimeTracker()
+		tracker.Location = time.UTC
+		day := time.Date(2017, 1, 10, 0, 0, 0, 0, time.UTC)
+		at := func(hours int, minutes int) time.Time {
+			return day.Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute)
+		}
+		thermostat := &Thermostat{DeviceID: "z1234", IsOnline: true, HvacState: Idle}
+		devices := &Devices{Thermostats: map[string]*Thermostat{"z1234": thermostat}}
+		update := func(state HvacState, t time.Time) {
+			thermostat.HvacState = state
+			tracker.Update(devices, t)
+		}
+		update(Idle, at(9, 55))
+
+		Convey("Heating should be counted by hour and by day", func() {
+			update(Heating, at(10, 0))
+			update(Heating, at(10, 20))
+			update(Idle, at(10, 45))
+			update(Heating, at(11, 50))
+			update(Idle, at(12, 20))
+			So(tracker.Hourly("z1234", at(10, 30)).Heating, ShouldEqual, 45*time.Minute)
+			So(tracker.Hourly("z1234", at(11, 0)).Heating, ShouldEqual, 10*time.Minute)
+			So(tracker.Hourly("z1234", at(11, 0)).HeatingCycles, ShouldEqual, 1)
+			So(tracker.Hourly("z1234", at(12, 0)).Heating, ShouldEqual, 20*time.Minute)
+			daily := tracker.Daily("z1234", at(23, 0))
+			So(daily.Heating, ShouldEqual, 75*time.Minute)
+			So(daily.Cooling, ShouldEqual, 0)
+			So(daily.HeatingCycles, ShouldEqual, 2)
+			So(daily.ShortCycles, ShouldEqual, 0)
+			So(tracker.Daily("z1234", at(24, 0)).Heating, ShouldEqual, 0)
+			So(tracker.Daily("unknown", day).Heating, ShouldEqual, 0)
+		})
+		Convey("Runs shorter than ShortCycle should be counted as short cycles", func() {
+			update(Cooling, at(13, 0))
+			update(Idle, at(13, 4))
+			update(Cooling, at(13, 10))
+			update(Heating, at(13, 15))
+			update(Idle, at(13, 40))
+			usage := tracker.Hourly("z1234", at(13, 0))
+			So(usage.Cooling, ShouldEqual, 9*time.Minute)
+			So(usage.Heating, ShouldEqual, 25*time.Minute)
+			So(usage.CoolingCycles, ShouldEqual, 2)
+			So(usage.HeatingCycles, ShouldEqual, 1)
+			So(usage.ShortCycles, ShouldEqual, 2)
+		})
+		Convey("Time across a gap in updates longer than MaxGap should not be counted", func() {
+			tracker.MaxGap = 30 * time.Minute
+			update(Heating, at(10, 0))
+			update(Heating, at(10, 5))
+			update(Idle, at(11, 0))
+			usage := tracker.Daily("z1234", day)
+			So(usage.Heating, ShouldEqual, 5*time.Minute)
+			So(usage.ShortCycles, ShouldEqual, 0)
+		})
+		Convey("Time while disconnected should not be counted", func() {
+			update(Heating, at(10, 0))
+			tracker.Disconnected(at(10, 10))
+			update(Heating, at(10, 20))
+			update(Heating, at(10, 30))
+			update(Idle, at(10, 31))
+			usage := tracker.Daily("z1234", day)
+			So(usage.Heating, ShouldEqual, 21*time.Minute)
+			So(usage.HeatingCycles, ShouldEqual, 1)
+			So(usage.ShortCycles, ShouldEqual, 0)
+		})
+		Convey("Time while offline should not be counted", func() {
+			update(Heating, at(10, 0))
+			thermostat.IsOnline = false
+			update(Heating, at(10, 10))
+			thermostat.IsOnline = true
+			update(Heating, at(10, 20))
+			update(Idle, at(10, 25))
+			So(tracker.Daily("z1234", day).Heating, ShouldEqual, 15*time.Minute)
+		})
+		Convey("A restarted tracker should only count what it saw", func() {
+			tracker = NewRuntimeTracker()
+			tracker.Location = time.UTC
+			update(Heating, at(10, 0))
+			update(Idle, at(10, 3))
+			usage := tracker.Daily("z1234", day)
+			So(usage.Heating, ShouldEqual, 3*time.Minute)
+			So(usage.HeatingCycles, ShouldEqual, 0)
+			So(usage.ShortCycles, ShouldEqual, 0)
+		})
+		Convey("Updates older than the last one should be ignored", func() {
+			update(Heating, at(10, 0))
+			update(Idle, at(10, 10))
+			update(Heating, at(10, 5))
+			update(Idle, at(10, 20))
+			So(tracker.Daily("z1234", day).Heating, ShouldEqual, 10*time.Minute)
+		})
+		Convey("The fan timer should be counted", func() {
+			thermostat.FanTimerActive = true
+			update(Idle, at(10, 0))
+			thermostat.FanTimerActive = false
+			update(Idle, at(10, 15))
+			update(Idle, at(10, 25))
+			So(tracker.Daily("z1234", day).Fan, ShouldEqual, 15*time.Minute)
+		})
+		Convey("Energy should be worked out from the rates", func() {
+			tracker.Rates["z1234"] = &RuntimeRates{
+				Heating: &EnergyRate{PerHour: 0.8, Unit: "therm"},
+				Cooling: &EnergyRate{PerHour: 3.5, Unit: "kWh"},
+				Fan:     &EnergyRate{PerHour: 0.5, Unit: "kWh"},
+			}
+			update(Heating, at(10, 0))
+			update(Cooling, at(10, 30))
+			thermostat.FanTimerActive = true
+			update(Idle, at(10, 45))
+			update(Idle, at(11, 15))
+			usage := tracker.Daily("z1234", day)
+			So(usage.Energy["therm"], ShouldAlmostEqual, 0.4)
+			So(usage.Energy["kWh"], ShouldAlmostEqual, 3.5/4+0.5/2)
+			So(tracker.Daily("z1234", day.AddDate(0, 0, 1)).Energy, ShouldBeEmpty)
+		})
+		Convey("Prune should forget old hours", func() {
+			update(Heating, at(10, 0))
+			update(Idle, at(11, 30))
+			tracker.Prune(at(11, 0))
+			So(tracker.Daily("z1234", day).Heating, ShouldEqual, 30*time.Minute)
+		})
+	})
+}
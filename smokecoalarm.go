@@ -0,0 +1,45 @@
+package nest
+
+import (
+	"time"
+)
+
+/*
+IsAlarming returns true when either the smoke or CO alarm is in a warning or emergency state
+
+	if alarm.IsAlarming() {
+		fmt.Println(alarm.Severity())
+	}
+*/
+func (s *SmokeCoAlarm) IsAlarming() bool {
+	return s.Severity() != AlarmOK
+}
+
+// Severity returns the most severe of the smoke and CO alarm states
+func (s *SmokeCoAlarm) Severity() AlarmState {
+	if s.CoAlarmState.Level() > s.SmokeAlarmState.Level() {
+		return s.CoAlarmState
+	}
+	if s.SmokeAlarmState.Level() > 0 {
+		return s.SmokeAlarmState
+	}
+	return AlarmOK
+}
+
+// NeedsBattery returns true when the battery should be replaced
+func (s *SmokeCoAlarm) NeedsBattery() bool {
+	return s.BatteryHealth == BatteryReplace
+}
+
+// DaysSinceManualTest returns the number of whole days since the last manual test, or -1 if it was never tested
+func (s *SmokeCoAlarm) DaysSinceManualTest() int {
+	return s.daysSinceManualTest(time.Now())
+}
+
+// daysSinceManualTest returns the number of whole days between the last manual test and now
+func (s *SmokeCoAlarm) daysSinceManualTest(now time.Time) int {
+	if s.LastManualTestTime.IsZero() {
+		return -1
+	}
+	return int(now.Sub(s.LastManualTestTime) / (24 * time.Hour))
+}
@@ -0,0 +1,44 @@
+package nest
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestSmokeCoAlarm(t *testing.T) {
+	Convey("Given a smokecoalarm", t, func() {
+		combined := &Combined{}
+		json.Unmarshal(combinedJSON(), combined)
+		alarm := combined.Devices.SmokeCoAlarms["RTMTKxsQTCxzVcsySOHPxKoF4OyCifrs"]
+		So(alarm.IsManualTestActive, ShouldBeTrue)
+		So(alarm.WhereName, ShouldEqual, "Hallway")
+
+		Convey("When all is well it should not be alarming", func() {
+			So(alarm.IsAlarming(), ShouldBeFalse)
+			So(alarm.Severity(), ShouldEqual, AlarmOK)
+			So(alarm.NeedsBattery(), ShouldBeFalse)
+		})
+
+		Convey("When CO is detected it should be alarming", func() {
+			alarm.CoAlarmState = AlarmWarning
+			So(alarm.IsAlarming(), ShouldBeTrue)
+			So(alarm.Severity(), ShouldEqual, AlarmWarning)
+			alarm.SmokeAlarmState = AlarmEmergency
+			So(alarm.Severity(), ShouldEqual, AlarmEmergency)
+		})
+
+		Convey("When the battery is low it should need a battery", func() {
+			alarm.BatteryHealth = BatteryReplace
+			So(alarm.NeedsBattery(), ShouldBeTrue)
+		})
+
+		Convey("We should know how long ago it was tested", func() {
+			now := alarm.LastManualTestTime.Add(90*24*time.Hour + time.Hour)
+			So(alarm.daysSinceManualTest(now), ShouldEqual, 90)
+			alarm.LastManualTestTime = time.Time{}
+			So(alarm.DaysSinceManualTest(), ShouldEqual, -1)
+		})
+	})
+}
@@ -0,0 +1,199 @@
+package nest
+
+// HvacMode represents the mode of a thermostat
+// https://developer.nest.com/documentation/api#hvac_mode
+type HvacMode string
+
+const (
+	// Cool sets HvacMode to "cool"
+	Cool HvacMode = "cool"
+	// Heat sets HvacMode to "heat"
+	Heat HvacMode = "heat"
+	// HeatCool sets HvacMode to "heat-cool"
+	HeatCool HvacMode = "heat-cool"
+	// Off sets HvacMode to "off"
+	Off HvacMode = "off"
+	// Eco sets HvacMode to "eco"
+	Eco HvacMode = "eco"
+)
+
+// String returns the HvacMode as sent to the Nest API
+func (m HvacMode) String() string {
+	return string(m)
+}
+
+// Valid reports whether the HvacMode is one known to the library
+func (m HvacMode) Valid() bool {
+	switch m {
+	case Cool, Heat, HeatCool, Off, Eco:
+		return true
+	}
+	return false
+}
+
+// HvacState represents what the HVAC system attached to a thermostat is doing
+// https://developer.nest.com/documentation/api#hvac_state
+type HvacState string
+
+const (
+	// Heating is the HvacState when the system is heating
+	Heating HvacState = "heating"
+	// Cooling is the HvacState when the system is cooling
+	Cooling HvacState = "cooling"
+	// Idle is the HvacState "off", reported when the system is not running
+	Idle HvacState = "off"
+)
+
+// String returns the HvacState as sent by the Nest API
+func (s HvacState) String() string {
+	return string(s)
+}
+
+// Valid reports whether the HvacState is one known to the library
+func (s HvacState) Valid() bool {
+	switch s {
+	case Heating, Cooling, Idle:
+		return true
+	}
+	return false
+}
+
+// AwayMode represents the away status of a structure
+// https://developer.nest.com/documentation/api#away
+type AwayMode string
+
+const (
+	// Home sets Away mode to "home"
+	Home AwayMode = "home"
+	// Away sets Away mode to "away"
+	Away AwayMode = "away"
+	// AutoAway sets Away mode to "auto-away"
+	AutoAway AwayMode = "auto-away"
+)
+
+// String returns the AwayMode as sent to the Nest API
+func (m AwayMode) String() string {
+	return string(m)
+}
+
+// Valid reports whether the AwayMode is one known to the library
+func (m AwayMode) Valid() bool {
+	switch m {
+	case Home, Away, AutoAway:
+		return true
+	}
+	return false
+}
+
+// AlarmState represents the CO or smoke alarm state of a smokecoalarm
+// https://developer.nest.com/documentation/api#co_alarm_state
+type AlarmState string
+
+const (
+	// AlarmOK is the AlarmState when nothing has been detected
+	AlarmOK AlarmState = "ok"
+	// AlarmWarning is the AlarmState when a heads-up has been given
+	AlarmWarning AlarmState = "warning"
+	// AlarmEmergency is the AlarmState when the alarm is sounding
+	AlarmEmergency AlarmState = "emergency"
+)
+
+// String returns the AlarmState as sent by the Nest API
+func (s AlarmState) String() string {
+	return string(s)
+}
+
+// Valid reports whether the AlarmState is one known to the library
+func (s AlarmState) Valid() bool {
+	switch s {
+	case AlarmOK, AlarmWarning, AlarmEmergency:
+		return true
+	}
+	return false
+}
+
+// Level ranks the AlarmState by severity, from 0 for ok up to 2 for emergency
+func (s AlarmState) Level() int {
+	switch s {
+	case AlarmWarning:
+		return 1
+	case AlarmEmergency:
+		return 2
+	}
+	return 0
+}
+
+// BatteryHealth represents the battery health of a smokecoalarm
+// https://developer.nest.com/documentation/api#battery_health
+type BatteryHealth string
+
+const (
+	// BatteryOK is the BatteryHealth when the battery is fine
+	BatteryOK BatteryHealth = "ok"
+	// BatteryReplace is the BatteryHealth when the battery should be replaced
+	BatteryReplace BatteryHealth = "replace"
+)
+
+// String returns the BatteryHealth as sent by the Nest API
+func (b BatteryHealth) String() string {
+	return string(b)
+}
+
+// Valid reports whether the BatteryHealth is one known to the library
+func (b BatteryHealth) Valid() bool {
+	switch b {
+	case BatteryOK, BatteryReplace:
+		return true
+	}
+	return false
+}
+
+// UIColorState represents the color of the ring on a smokecoalarm
+// https://developer.nest.com/documentation/api#ui_color_state
+type UIColorState string
+
+const (
+	// Gray is the UIColorState when the device is offline
+	Gray UIColorState = "gray"
+	// Green is the UIColorState when all is well
+	Green UIColorState = "green"
+	// Yellow is the UIColorState for warnings and low batteries
+	Yellow UIColorState = "yellow"
+	// Red is the UIColorState for emergencies
+	Red UIColorState = "red"
+)
+
+// String returns the UIColorState as sent by the Nest API
+func (c UIColorState) String() string {
+	return string(c)
+}
+
+// Valid reports whether the UIColorState is one known to the library
+func (c UIColorState) Valid() bool {
+	switch c {
+	case Gray, Green, Yellow, Red:
+		return true
+	}
+	return false
+}
+
+// SecurityState represents the security state of a structure
+// https://developer.nest.com/documentation/api#wwn_security_state
+type SecurityState string
+
+const (
+	// SecurityOK is the SecurityState when no unexpected activity is detected
+	SecurityOK SecurityState = "ok"
+	// SecurityDeter is the SecurityState when a deterrent should be activated
+	SecurityDeter SecurityState = "deter"
+)
+
+// String returns the SecurityState as sent by the Nest API
+func (s SecurityState) String() string {
+	return string(s)
+}
+
+// Valid reports whether the SecurityState is one known to the library
+func (s SecurityState) Valid() bool {
+	return s == SecurityOK || s == SecurityDeter
+}
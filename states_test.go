@@ -0,0 +1,56 @@
+package nest
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestStates(t *testing.T) {
+	Convey("Given a JSON object with combined", t, func() {
+		combined := &Combined{}
+		err := json.Unmarshal(combinedJSON(), combined)
+		So(err, ShouldBeNil)
+
+		Convey("Thermostat states should decode to known values", func() {
+			thermostat := combined.Devices.Thermostats["peyiJNo0IldT2YlIVtYaGQ"]
+			So(thermostat.HvacMode, ShouldEqual, Heat)
+			So(thermostat.HvacMode.Valid(), ShouldBeTrue)
+			So(thermostat.HvacState, ShouldEqual, Heating)
+			So(thermostat.HvacState.Valid(), ShouldBeTrue)
+		})
+
+		Convey("Smokecoalarm states should decode to known values", func() {
+			alarm := combined.Devices.SmokeCoAlarms["RTMTKxsQTCxzVcsySOHPxKoF4OyCifrs"]
+			So(alarm.BatteryHealth, ShouldEqual, BatteryOK)
+			So(alarm.CoAlarmState, ShouldEqual, AlarmOK)
+			So(alarm.SmokeAlarmState.Valid(), ShouldBeTrue)
+			So(alarm.UIColorState, ShouldEqual, Gray)
+		})
+
+		Convey("Structure states should decode to known values", func() {
+			structure := combined.Structures["VqFabWH21nwVyd4RWgJgNb292wa7hG_dUwo2i2SG7j3-BOLY0BA4sw"]
+			So(structure.Away, ShouldEqual, Home)
+			So(structure.Away.Valid(), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given states the library does not know", t, func() {
+		thermostat := &Thermostat{}
+		err := json.Unmarshal([]byte(`{"hvac_mode":"dry","hvac_state":"defrosting"}`), thermostat)
+		So(err, ShouldBeNil)
+		So(thermostat.HvacMode.Valid(), ShouldBeFalse)
+		So(thermostat.HvacState.Valid(), ShouldBeFalse)
+		So(AwayMode("vacation").Valid(), ShouldBeFalse)
+		So(AlarmState("panic").Valid(), ShouldBeFalse)
+		So(BatteryHealth("dead").Valid(), ShouldBeFalse)
+		So(UIColorState("blue").Valid(), ShouldBeFalse)
+	})
+
+	Convey("States should marshal as their API strings", t, func() {
+		So(HeatCool.String(), ShouldEqual, "heat-cool")
+		So(AutoAway.String(), ShouldEqual, "auto-away")
+		data, _ := json.Marshal(map[string]HvacMode{"hvac_mode": HeatCool})
+		So(string(data), ShouldEqual, `{"hvac_mode":"heat-cool"}`)
+	})
+}
@@ -2,11 +2,41 @@ package nest
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"reflect"
 	"strings"
+	"time"
 )
 
+const (
+	// confirmMinBackoff is how long confirm waits before reconnecting after the first failed stream
+	confirmMinBackoff = 100 * time.Millisecond
+	// confirmMaxBackoff is the longest confirm waits between stream connections
+	confirmMaxBackoff = 2 * time.Second
+	// MaxWatchRetryDelay is the longest WatchDevices and WatchStructures wait between stream connections
+	MaxWatchRetryDelay = time.Minute
+)
+
+// ErrStreamEnded is passed to a watch callback when the Nest API closes the stream
+var ErrStreamEnded = errors.New("nest: stream ended")
+
+// StreamError is passed to a watch callback when a stream is answered with an error status
+type StreamError struct {
+	Status      string
+	StatusCode  int
+	Description string
+}
+
+// Error describes the refused stream
+func (e *StreamError) Error() string {
+	return "nest: stream refused with " + e.Status + ": " + e.Description
+}
+
 /*
 DevicesStream emits events from the Nest devices REST streaming API
 
@@ -52,6 +82,236 @@ func (c *Client) watchDevicesStream(resp *http.Response, callback func(devices *
 	}
 }
 
+/*
+WatchDevices emits events from the Nest devices REST streaming API until ctx is done, which also closes
+the stream. Whenever the stream fails to connect, is refused or ends, the callback gets the error and the
+stream is reconnected after retryDelay, doubling for each failure in a row up to MaxWatchRetryDelay.
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go client.WatchDevices(ctx, 5*time.Second, func(devices *nest.Devices, err error) {
+		fmt.Println(devices, err)
+	})
+	...
+	cancel()
+*/
+func (c *Client) WatchDevices(ctx context.Context, retryDelay time.Duration, callback func(devices *Devices, err error)) {
+	c.watch(ctx, "/devices.json", retryDelay, func(data []byte) {
+		devicesEvent := &DevicesEvent{}
+		json.Unmarshal(data, devicesEvent)
+		if devicesEvent.Data != nil {
+			c.associateClientToDevices(devicesEvent.Data)
+			callback(devicesEvent.Data, nil)
+		}
+	}, func(err error) {
+		callback(nil, err)
+	})
+}
+
+/*
+WatchStructures emits events from the Nest structures REST streaming API until ctx is done, reconnecting
+like WatchDevices
+
+	go client.WatchStructures(ctx, 5*time.Second, func(structures map[string]*nest.Structure, err error) {
+		fmt.Println(structures, err)
+	})
+*/
+func (c *Client) WatchStructures(ctx context.Context, retryDelay time.Duration, callback func(structures map[string]*Structure, err error)) {
+	c.watch(ctx, "/structures.json", retryDelay, func(data []byte) {
+		structuresEvent := &StructuresEvent{}
+		json.Unmarshal(data, structuresEvent)
+		if structuresEvent.Data != nil {
+			c.associateClientToStructures(structuresEvent.Data)
+			callback(structuresEvent.Data, nil)
+		}
+	}, func(err error) {
+		callback(nil, err)
+	})
+}
+
+// watch keeps the stream at path connected until ctx is done, passing each event's data to event and each failure to fail
+func (c *Client) watch(ctx context.Context, path string, retryDelay time.Duration, event func(data []byte), fail func(err error)) {
+	backoff := retryDelay
+	for {
+		received, err := c.watchOnce(ctx, path, event)
+		if ctx.Err() != nil {
+			return
+		}
+		fail(err)
+		if received {
+			backoff = retryDelay
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > MaxWatchRetryDelay {
+			backoff = MaxWatchRetryDelay
+		}
+	}
+}
+
+// watchOnce reads one stream connection until it fails or ends, reporting whether any event was received
+func (c *Client) watchOnce(ctx context.Context, path string, event func(data []byte)) (bool, error) {
+	if c.RedirectURL == "" {
+		_, err := c.setRedirectURL()
+		if err != nil {
+			return false, err
+		}
+	}
+	req, _ := http.NewRequest("GET", c.RedirectURL+path+"?auth="+c.Token, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		apiError := &APIError{}
+		json.Unmarshal(body, apiError)
+		return false, &StreamError{Status: resp.Status, StatusCode: resp.StatusCode, Description: apiError.Error}
+	}
+	received := false
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return received, ErrStreamEnded
+		}
+		if err != nil {
+			return received, err
+		}
+		value := parseStreamData(line)
+		if value != "" {
+			received = true
+			event([]byte(value))
+		}
+	}
+}
+
+// confirm watches the stream at path until the object found under keys holds every value
+// in body, then returns that object as JSON. Failed or ended streams are reconnected with a
+// growing backoff, and a 401 or 403 is returned at once as it will not go away by retrying.
+func (c *Client) confirm(path string, keys []string, body []byte) ([]byte, *APIError) {
+	expected := make(map[string]interface{})
+	json.Unmarshal(body, &expected)
+	ctx, cancel := context.WithTimeout(context.Background(), c.ConfirmTimeout)
+	defer cancel()
+	backoff := confirmMinBackoff
+	for {
+		object, apiErr := c.watchForValues(ctx, path, keys, expected)
+		if object != nil {
+			return object, nil
+		}
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		select {
+		case <-ctx.Done():
+			return nil, &APIError{
+				Error:       "confirm_timeout",
+				Description: "Timed out waiting for the stream to confirm the update",
+			}
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > confirmMaxBackoff {
+			backoff = confirmMaxBackoff
+		}
+	}
+}
+
+// watchForValues reads one stream connection, returning the first matching object, or nil if the
+// stream fails or ends. An error is returned only when the stream is refused for good.
+func (c *Client) watchForValues(ctx context.Context, path string, keys []string, expected map[string]interface{}) ([]byte, *APIError) {
+	req, _ := http.NewRequest("GET", c.RedirectURL+path+"?auth="+c.Token, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		body, _ := ioutil.ReadAll(resp.Body)
+		apiError := &APIError{}
+		json.Unmarshal(body, apiError)
+		apiError = generateAPIError(apiError.Error)
+		apiError.Status = resp.Status
+		apiError.StatusCode = resp.StatusCode
+		return nil, apiError
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, nil
+		}
+		value := parseStreamData(line)
+		if value == "" {
+			continue
+		}
+		event := &struct {
+			Data json.RawMessage `json:"data"`
+		}{}
+		json.Unmarshal([]byte(value), event)
+		object := lookupObject(event.Data, keys)
+		if object != nil && hasValues(object, expected) {
+			return object, nil
+		}
+	}
+}
+
+// lookupObject walks down the JSON tree in data following keys
+func lookupObject(data json.RawMessage, keys []string) json.RawMessage {
+	for _, key := range keys {
+		children := make(map[string]json.RawMessage)
+		if json.Unmarshal(data, &children) != nil {
+			return nil
+		}
+		data = children[key]
+	}
+	return data
+}
+
+// hasValues reports whether the JSON object holds all expected values, treating missing keys as zero values.
+// Temperatures are compared as the Nest API rounds them, to whole degrees F and half degrees C.
+func hasValues(object []byte, expected map[string]interface{}) bool {
+	actual := make(map[string]interface{})
+	if json.Unmarshal(object, &actual) != nil {
+		return false
+	}
+	for key, value := range expected {
+		current, ok := actual[key]
+		if !ok && (value == nil || reflect.ValueOf(value).IsZero()) {
+			continue
+		}
+		if !reflect.DeepEqual(roundTemperature(key, current), roundTemperature(key, value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// roundTemperature rounds the value of a temperature field the way the Nest API stores it, leaving other values as they are
+func roundTemperature(key string, value interface{}) interface{} {
+	number, ok := value.(float64)
+	if !ok || !strings.Contains(key, "temp") {
+		return value
+	}
+	switch {
+	case strings.HasSuffix(key, "_f"):
+		return Temperature{Value: number, Scale: Fahrenheit}.Round().Value
+	case strings.HasSuffix(key, "_c"):
+		return Temperature{Value: number, Scale: Celsius}.Round().Value
+	}
+	return value
+}
+
 // parseStreamData takes a line of the stream and parses out the JSON data
 func parseStreamData(line string) string {
 	sections := strings.SplitN(line, ":", 2)
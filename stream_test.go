@@ -1,8 +1,13 @@
 package nest
 
 import (
+	"context"
 	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestDevicesStream(t *testing.T) {
@@ -25,7 +30,101 @@ func TestDevicesStream(t *testing.T) {
 			So(devices.Thermostats["z1234"].StructureID, ShouldEqual, "s1234")
 			So(client.Token, ShouldEqual, devices.Thermostats["z1234"].Client.Token)
 			So(client.Token, ShouldEqual, devices.SmokeCoAlarms["a1234"].Client.Token)
+			So(client.Token, ShouldEqual, devices.Cameras["c1234"].Client.Token)
 		}
 		So(cnt, ShouldEqual, 2)
 	})
 }
+
+func TestConfirmRetries(t *testing.T) {
+	Convey("When confirming against a stream that keeps failing", t, func() {
+		var attempts int32
+		status := http.StatusServiceUnavailable
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":"failed"}`))
+		}))
+		defer server.Close()
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.RedirectURL = server.URL
+		client.ConfirmTimeout = 500 * time.Millisecond
+
+		Convey("It should back off between attempts until it times out", func() {
+			_, err := client.confirm("/devices.json", []string{"thermostats", "z1234"}, []byte(`{"label":"Den"}`))
+			So(err.Error, ShouldEqual, "confirm_timeout")
+			So(atomic.LoadInt32(&attempts), ShouldBeBetweenOrEqual, 2, 5)
+		})
+		Convey("It should give up at once when unauthorized", func() {
+			status = http.StatusUnauthorized
+			start := time.Now()
+			_, err := client.confirm("/devices.json", []string{"thermostats", "z1234"}, []byte(`{"label":"Den"}`))
+			So(time.Since(start), ShouldBeLessThan, 200*time.Millisecond)
+			So(err.StatusCode, ShouldEqual, http.StatusUnauthorized)
+			So(err.Description, ShouldEqual, "failed")
+			So(atomic.LoadInt32(&attempts), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestHasValues(t *testing.T) {
+	Convey("When checking a streamed object against the values written", t, func() {
+		object := []byte(`{"target_temperature_f":72,"target_temperature_c":22,"label":"Den","fan_timer_duration":15}`)
+
+		Convey("Temperatures should match once rounded like the Nest API", func() {
+			So(hasValues(object, map[string]interface{}{"target_temperature_f": 72.4, "target_temperature_c": 22.2}), ShouldBeTrue)
+			So(hasValues(object, map[string]interface{}{"target_temperature_f": 72.6}), ShouldBeFalse)
+			So(hasValues(object, map[string]interface{}{"target_temperature_c": 22.3}), ShouldBeFalse)
+		})
+		Convey("Other values should match exactly", func() {
+			So(hasValues(object, map[string]interface{}{"label": "Den", "fan_timer_duration": 15.0}), ShouldBeTrue)
+			So(hasValues(object, map[string]interface{}{"fan_timer_duration": 15.4}), ShouldBeFalse)
+			So(hasValues(object, map[string]interface{}{"label": "Den "}), ShouldBeFalse)
+		})
+	})
+}
+
+func TestWatchDevices(t *testing.T) {
+	Convey("When watching a stream that ends after one event and is then refused", t, func() {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("event: put\ndata: {\"path\":\"/\",\"data\":{\"thermostats\":{\"z1234\":{\"device_id\":\"z1234\"}}}}\n\n"))
+				return
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"unauthorized"}`))
+		}))
+		defer server.Close()
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.RedirectURL = server.URL
+		events := make(chan interface{}, 100)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			client.WatchDevices(ctx, 20*time.Millisecond, func(devices *Devices, err error) {
+				if err != nil {
+					events <- err
+					return
+				}
+				events <- devices
+			})
+			close(done)
+		}()
+
+		devices := (<-events).(*Devices)
+		So(devices.Thermostats["z1234"].Client, ShouldEqual, client)
+		So(<-events, ShouldEqual, ErrStreamEnded)
+		refused := (<-events).(*StreamError)
+		So(refused.StatusCode, ShouldEqual, http.StatusUnauthorized)
+		So(refused.Description, ShouldEqual, "unauthorized")
+
+		time.Sleep(200 * time.Millisecond)
+		So(atomic.LoadInt32(&attempts), ShouldBeBetweenOrEqual, 3, 5)
+		cancel()
+		<-done
+	})
+}
@@ -1,6 +1,7 @@
 package nest
 
 import (
+	"net/http"
 	"time"
 )
 
@@ -23,6 +24,15 @@ type Client struct {
 	AccessTokenURL    string
 	APIURL            string
 	RedirectURL       string
+	// ConfirmTimeout, when set, makes writes wait up to this long for the
+	// REST streaming API to report the new values before returning
+	ConfirmTimeout time.Duration
+	// ClampLockedTemps moves temperatures outside a locked thermostat's
+	// range to the nearest bound instead of rejecting them
+	ClampLockedTemps bool
+	// HTTPClient, when set, is used for every request instead of
+	// http.DefaultClient, such as to record or replay traffic
+	HTTPClient *http.Client
 }
 
 // Access represents a Nest access token object
@@ -52,51 +62,70 @@ type StructuresEvent struct {
 	Data map[string]*Structure `json:"data,omitempty"`
 }
 
-// Devices represents devices that include thermostats and smokecoalarms
+// Devices represents devices that include thermostats, smokecoalarms and cameras
 type Devices struct {
 	Thermostats   map[string]*Thermostat   `json:"thermostats,omitempty"`
 	SmokeCoAlarms map[string]*SmokeCoAlarm `json:"smoke_co_alarms,omitempty"`
+	Cameras       map[string]*Camera       `json:"cameras,omitempty"`
 }
 
 /*
-Thermostat represents a Nest thermostat object
+Thermostat represents a Nest thermostat object. The AwayTemperature fields are
+deprecated aliases of the EcoTemperature fields and are kept in sync with them.
 https://developer.nest.com/documentation/api#thermostats
 https://developer.nest.com/documentation/how-to-thermostats-object
 */
 type Thermostat struct {
-	DeviceID               string    `json:"device_id,omitempty"`
-	Locale                 string    `json:"locale,omitempty"`
-	SoftwareVersion        string    `json:"software_version,omitempty"`
-	StructureID            string    `json:"structure_id,omitempty"`
-	Name                   string    `json:"name,omitempty"`
-	NameLong               string    `json:"name_long,omitempty"`
-	LastConnection         time.Time `json:"last_connection,omitempty"`
-	IsOnline               bool      `json:"is_online,omitempty"`
-	CanCool                bool      `json:"can_cool,omitempty"`
-	CanHeat                bool      `json:"can_heat,omitempty"`
-	IsUsingEmergencyHeat   bool      `json:"is_using_emergency_heat,omitempty"`
-	HasFan                 bool      `json:"has_fan,omitempty"`
-	FanTimerActive         bool      `json:"fan_timer_active,omitempty"`
-	FanTimerTimeout        time.Time `json:"fan_timer_timeout,omitempty"`
-	HasLeaf                bool      `json:"has_leaf,omitempty"`
-	TemperatureScale       string    `json:"temperature_scale,omitempty"`
-	TargetTemperatureF     int       `json:"target_temperature_f,omitempty"`
-	TargetTemperatureC     float32   `json:"target_temperature_c,omitempty"`
-	TargetTemperatureHighF int       `json:"target_temperature_high_f,omitempty"`
-	TargetTemperatureHighC float32   `json:"target_temperature_high_c,omitempty"`
-	TargetTemperatureLowF  int       `json:"target_temperature_low_f,omitempty"`
-	TargetTemperatureLowC  float32   `json:"target_temperature_low_c,omitempty"`
-	AwayTemperatureHighF   int       `json:"away_temperature_high_f,omitempty"`
-	AwayTemperatureHighC   float32   `json:"away_temperature_high_c,omitempty"`
-	AwayTemperatureLowF    int       `json:"away_temperature_low_f,omitempty"`
-	AwayTemperatureLowC    float32   `json:"away_temperature_low_c,omitempty"`
-	HvacMode               string    `json:"hvac_mode,omitempty"`
-	AmbientTemperatureF    int       `json:"ambient_temperature_f,omitempty"`
-	AmbientTemperatureC    float32   `json:"ambient_temperature_c,omitempty"`
-	Humidity               int       `json:"humidity,omitempty"`
-	HvacState              string    `json:"hvac_state,omitempty"`
-	WhereID                string    `json:"where_id,omitempty"`
-	Client                 *Client
+	DeviceID                  string    `json:"device_id,omitempty"`
+	Locale                    string    `json:"locale,omitempty"`
+	SoftwareVersion           string    `json:"software_version,omitempty"`
+	StructureID               string    `json:"structure_id,omitempty"`
+	Name                      string    `json:"name,omitempty"`
+	NameLong                  string    `json:"name_long,omitempty"`
+	Label                     string    `json:"label,omitempty"`
+	LastConnection            time.Time `json:"last_connection,omitempty"`
+	IsOnline                  bool      `json:"is_online,omitempty"`
+	CanCool                   bool      `json:"can_cool,omitempty"`
+	CanHeat                   bool      `json:"can_heat,omitempty"`
+	IsUsingEmergencyHeat      bool      `json:"is_using_emergency_heat,omitempty"`
+	HasFan                    bool      `json:"has_fan,omitempty"`
+	FanTimerActive            bool      `json:"fan_timer_active,omitempty"`
+	FanTimerTimeout           time.Time `json:"fan_timer_timeout,omitempty"`
+	FanTimerDuration          int       `json:"fan_timer_duration,omitempty"`
+	HasLeaf                   bool      `json:"has_leaf,omitempty"`
+	TemperatureScale          Scale     `json:"temperature_scale,omitempty"`
+	TargetTemperatureF        int       `json:"target_temperature_f,omitempty"`
+	TargetTemperatureC        float32   `json:"target_temperature_c,omitempty"`
+	TargetTemperatureHighF    int       `json:"target_temperature_high_f,omitempty"`
+	TargetTemperatureHighC    float32   `json:"target_temperature_high_c,omitempty"`
+	TargetTemperatureLowF     int       `json:"target_temperature_low_f,omitempty"`
+	TargetTemperatureLowC     float32   `json:"target_temperature_low_c,omitempty"`
+	EcoTemperatureHighF       int       `json:"eco_temperature_high_f,omitempty"`
+	EcoTemperatureHighC       float32   `json:"eco_temperature_high_c,omitempty"`
+	EcoTemperatureLowF        int       `json:"eco_temperature_low_f,omitempty"`
+	EcoTemperatureLowC        float32   `json:"eco_temperature_low_c,omitempty"`
+	AwayTemperatureHighF      int       `json:"away_temperature_high_f,omitempty"`
+	AwayTemperatureHighC      float32   `json:"away_temperature_high_c,omitempty"`
+	AwayTemperatureLowF       int       `json:"away_temperature_low_f,omitempty"`
+	AwayTemperatureLowC       float32   `json:"away_temperature_low_c,omitempty"`
+	HvacMode                  HvacMode  `json:"hvac_mode,omitempty"`
+	PreviousHvacMode          HvacMode  `json:"previous_hvac_mode,omitempty"`
+	AmbientTemperatureF       int       `json:"ambient_temperature_f,omitempty"`
+	AmbientTemperatureC       float32   `json:"ambient_temperature_c,omitempty"`
+	Humidity                  int       `json:"humidity,omitempty"`
+	HvacState                 HvacState `json:"hvac_state,omitempty"`
+	TimeToTarget              string    `json:"time_to_target,omitempty"`
+	TimeToTargetTraining      string    `json:"time_to_target_training,omitempty"`
+	SunlightCorrectionEnabled bool      `json:"sunlight_correction_enabled,omitempty"`
+	SunlightCorrectionActive  bool      `json:"sunlight_correction_active,omitempty"`
+	IsLocked                  bool      `json:"is_locked,omitempty"`
+	LockedTempMinF            int       `json:"locked_temp_min_f,omitempty"`
+	LockedTempMaxF            int       `json:"locked_temp_max_f,omitempty"`
+	LockedTempMinC            float32   `json:"locked_temp_min_c,omitempty"`
+	LockedTempMaxC            float32   `json:"locked_temp_max_c,omitempty"`
+	WhereID                   string    `json:"where_id,omitempty"`
+	WhereName                 string    `json:"where_name,omitempty"`
+	Client                    *Client   `json:"-"`
 }
 
 // Tempratures represents all of the possible temprature settings for a Nest thermostat
@@ -115,19 +144,72 @@ https://developer.nest.com/documentation/api#smoke_co_alarms
 https://developer.nest.com/documentation/how-to-smoke-co-alarms-object
 */
 type SmokeCoAlarm struct {
-	DeviceID        string    `json:"device_id,omitempty"`
-	Locale          string    `json:"locale,omitempty"`
-	SoftwareVersion string    `json:"software_version,omitempty"`
-	StructureID     string    `json:"structure_id,omitempty"`
-	Name            string    `json:"name,omitempty"`
-	NameLong        string    `json:"name_long,omitempty"`
-	LastConnection  time.Time `json:"last_connection,omitempty"`
-	IsOnline        bool      `json:"is_online,omitempty"`
-	BatteryHealth   string    `json:"battery_health,omitempty"`
-	CoAlarmState    string    `json:"co_alarm_state,omitempty"`
-	SmokeAlarmState string    `json:"smoke_alarm_state,omitempty"`
-	UIColorState    string    `json:"ui_color_state,omitempty"`
-	Client          *Client
+	DeviceID           string        `json:"device_id,omitempty"`
+	Locale             string        `json:"locale,omitempty"`
+	SoftwareVersion    string        `json:"software_version,omitempty"`
+	StructureID        string        `json:"structure_id,omitempty"`
+	Name               string        `json:"name,omitempty"`
+	NameLong           string        `json:"name_long,omitempty"`
+	LastConnection     time.Time     `json:"last_connection,omitempty"`
+	IsOnline           bool          `json:"is_online,omitempty"`
+	BatteryHealth      BatteryHealth `json:"battery_health,omitempty"`
+	CoAlarmState       AlarmState    `json:"co_alarm_state,omitempty"`
+	SmokeAlarmState    AlarmState    `json:"smoke_alarm_state,omitempty"`
+	UIColorState       UIColorState  `json:"ui_color_state,omitempty"`
+	IsManualTestActive bool          `json:"is_manual_test_active,omitempty"`
+	LastManualTestTime time.Time     `json:"last_manual_test_time,omitempty"`
+	WhereID            string        `json:"where_id,omitempty"`
+	WhereName          string        `json:"where_name,omitempty"`
+	Client             *Client       `json:"-"`
+}
+
+/*
+Camera represents a Nest camera object
+https://developer.nest.com/documentation/api#cameras
+https://developer.nest.com/documentation/cloud/camera-guide
+*/
+type Camera struct {
+	DeviceID              string          `json:"device_id,omitempty"`
+	SoftwareVersion       string          `json:"software_version,omitempty"`
+	StructureID           string          `json:"structure_id,omitempty"`
+	WhereID               string          `json:"where_id,omitempty"`
+	WhereName             string          `json:"where_name,omitempty"`
+	Name                  string          `json:"name,omitempty"`
+	NameLong              string          `json:"name_long,omitempty"`
+	IsOnline              bool            `json:"is_online,omitempty"`
+	IsStreaming           bool            `json:"is_streaming,omitempty"`
+	IsAudioInputEnabled   bool            `json:"is_audio_input_enabled,omitempty"`
+	LastIsOnlineChange    time.Time       `json:"last_is_online_change,omitempty"`
+	IsVideoHistoryEnabled bool            `json:"is_video_history_enabled,omitempty"`
+	WebURL                string          `json:"web_url,omitempty"`
+	AppURL                string          `json:"app_url,omitempty"`
+	IsPublicShareEnabled  bool            `json:"is_public_share_enabled,omitempty"`
+	PublicShareURL        string          `json:"public_share_url,omitempty"`
+	SnapshotURL           string          `json:"snapshot_url,omitempty"`
+	ActivityZones         []*ActivityZone `json:"activity_zones,omitempty"`
+	LastEvent             *CameraEvent    `json:"last_event,omitempty"`
+	Client                *Client         `json:"-"`
+}
+
+// ActivityZone represents a named area of a camera's view
+type ActivityZone struct {
+	Name string `json:"name,omitempty"`
+	ID   int    `json:"id,omitempty"`
+}
+
+// CameraEvent represents the last sound, motion or person event seen by a camera
+type CameraEvent struct {
+	HasSound         bool      `json:"has_sound,omitempty"`
+	HasMotion        bool      `json:"has_motion,omitempty"`
+	HasPerson        bool      `json:"has_person,omitempty"`
+	StartTime        time.Time `json:"start_time,omitempty"`
+	EndTime          time.Time `json:"end_time,omitempty"`
+	URLsExpireTime   time.Time `json:"urls_expire_time,omitempty"`
+	WebURL           string    `json:"web_url,omitempty"`
+	AppURL           string    `json:"app_url,omitempty"`
+	ImageURL         string    `json:"image_url,omitempty"`
+	AnimatedImageURL string    `json:"animated_image_url,omitempty"`
+	ActivityZoneIDs  []string  `json:"activity_zone_ids,omitempty"`
 }
 
 /*
@@ -136,17 +218,25 @@ https://developer.nest.com/documentation/api#structures
 https://developer.nest.com/documentation/how-to-structures-object
 */
 type Structure struct {
-	StructureID         string    `json:"structure_id,omitempty"`
-	Thermostats         []string  `json:"thermostats,omitempty"`
-	SmokeCoAlarms       []string  `json:"smoke_co_alarms,omitempty"`
-	Away                string    `json:"away,omitempty"`
-	Name                string    `json:"name,omitempty"`
-	CountryCode         string    `json:"country_code,omitempty"`
-	PeakPeriodStartTime time.Time `json:"peak_period_start_time,omitempty"`
-	PeakPeriodEndTime   time.Time `json:"peak_period_end_time,omitempty"`
-	TimeZone            string    `json:"time_zone,omitempty"`
-	ETA                 *ETA      `json:"eta,omitempty"`
-	Client              *Client
+	StructureID         string                         `json:"structure_id,omitempty"`
+	Thermostats         []string                       `json:"thermostats,omitempty"`
+	SmokeCoAlarms       []string                       `json:"smoke_co_alarms,omitempty"`
+	Cameras             []string                       `json:"cameras,omitempty"`
+	Away                AwayMode                       `json:"away,omitempty"`
+	Name                string                         `json:"name,omitempty"`
+	CountryCode         string                         `json:"country_code,omitempty"`
+	PostalCode          string                         `json:"postal_code,omitempty"`
+	PeakPeriodStartTime time.Time                      `json:"peak_period_start_time,omitempty"`
+	PeakPeriodEndTime   time.Time                      `json:"peak_period_end_time,omitempty"`
+	TimeZone            string                         `json:"time_zone,omitempty"`
+	RhrEnrollment       bool                           `json:"rhr_enrollment,omitempty"`
+	CoAlarmState        AlarmState                     `json:"co_alarm_state,omitempty"`
+	SmokeAlarmState     AlarmState                     `json:"smoke_alarm_state,omitempty"`
+	WwnSecurityState    SecurityState                  `json:"wwn_security_state,omitempty"`
+	Devices             map[string]map[string][]string `json:"devices,omitempty"`
+	ETA                 *ETA                           `json:"eta,omitempty"`
+	Wheres              map[string]*Where              `json:"wheres,omitempty"`
+	Client              *Client                        `json:"-"`
 }
 
 // Eta represents an eta object (estimated time of a arrival for a structure)
@@ -155,3 +245,9 @@ type ETA struct {
 	EstimatedArrivalWindowBegin time.Time `json:"estimated_arrival_window_begin,omitempty"`
 	EstimatedArrivalWindowEnd   time.Time `json:"estimated_arrival_window_end,omitempty"`
 }
+
+// Where represents a room or area of a structure that devices can be assigned to
+type Where struct {
+	WhereID string `json:"where_id,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
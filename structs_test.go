@@ -22,6 +22,18 @@ func TestCombined(t *testing.T) {
 			}
 		})
 
+		Convey("We should get the thermostat details", func() {
+			thermostat := combined.Devices.Thermostats["peyiJNo0IldT2YlIVtYaGQ"]
+			So(thermostat.Label, ShouldEqual, "Upstairs")
+			So(thermostat.FanTimerDuration, ShouldEqual, 15)
+			So(thermostat.TimeToTarget, ShouldEqual, "~15")
+			So(thermostat.TimeToTargetTraining, ShouldEqual, "ready")
+			So(thermostat.LockedTempMinF, ShouldEqual, 65)
+			So(thermostat.LockedTempMaxC, ShouldEqual, 24)
+			So(thermostat.WhereName, ShouldEqual, "Hallway")
+			So(thermostat.HvacState, ShouldEqual, Heating)
+		})
+
 		Convey("We should get smokecoalarms", func() {
 			So(len(combined.Devices.SmokeCoAlarms), ShouldEqual, 1)
 			for key, value := range combined.Devices.SmokeCoAlarms {
@@ -30,6 +42,14 @@ func TestCombined(t *testing.T) {
 			}
 		})
 
+		Convey("We should get cameras", func() {
+			So(len(combined.Devices.Cameras), ShouldEqual, 1)
+			for key, value := range combined.Devices.Cameras {
+				So(key, ShouldEqual, combined.Devices.Cameras["awJo6rHcRE3Sr2M1TAvrBGYSb2ZgqtLXJFZI5HyOdvKIj"].DeviceID)
+				checkFields(value)
+			}
+		})
+
 		Convey("We should get structures", func() {
 			So(len(combined.Structures), ShouldEqual, 1)
 			for key, value := range combined.Structures {
@@ -38,6 +58,16 @@ func TestCombined(t *testing.T) {
 			}
 		})
 
+		Convey("We should get the structure details", func() {
+			structure := combined.Structures["VqFabWH21nwVyd4RWgJgNb292wa7hG_dUwo2i2SG7j3-BOLY0BA4sw"]
+			So(structure.PostalCode, ShouldEqual, "94304")
+			So(structure.RhrEnrollment, ShouldBeTrue)
+			So(structure.CoAlarmState, ShouldEqual, AlarmOK)
+			So(structure.SmokeAlarmState, ShouldEqual, AlarmOK)
+			So(structure.WwnSecurityState, ShouldEqual, SecurityOK)
+			So(structure.Devices["$company"]["$product_type"], ShouldHaveLength, 1)
+		})
+
 		Convey("Should get an eta", func() {
 			So(combined.Structures["VqFabWH21nwVyd4RWgJgNb292wa7hG_dUwo2i2SG7j3-BOLY0BA4sw"].ETA.TripID, ShouldEqual, "myTripHome1024")
 			checkFields(combined.Structures["VqFabWH21nwVyd4RWgJgNb292wa7hG_dUwo2i2SG7j3-BOLY0BA4sw"].ETA)
@@ -85,6 +115,7 @@ func combinedJSON() []byte {
 		                "structure_id": "VqFabWH21nwVyd4RWgJgNb292wa7hG_dUwo2i2SG7j3-BOLY0BA4sw",
 		                "name": "Hcombinedway (upstairs)",
 		                "name_long": "Hcombinedway Thermostat (upstairs)",
+		                "label": "Upstairs",
 		                "last_connection": "2014-03-02T23:20:19+00:00",
 		                "is_online": true,
 		                "can_cool": true,
@@ -93,6 +124,7 @@ func combinedJSON() []byte {
 		                "has_fan": true,
 		                "fan_timer_active": true,
 		                "fan_timer_timeout": "2014-03-02T23:20:19+00:00",
+		                "fan_timer_duration": 15,
 		                "has_leaf": true,
 		                "temperature_scale": "C",
 		                "target_temperature_f": 72,
@@ -110,7 +142,17 @@ func combinedJSON() []byte {
 		                "ambient_temperature_c": 21.5,
 		                "humidity": 35,
 		                "hvac_state": "heating",
-		                "where_id": "d6reb_OZTM..."
+		                "time_to_target": "~15",
+		                "time_to_target_training": "ready",
+		                "sunlight_correction_enabled": true,
+		                "sunlight_correction_active": true,
+		                "is_locked": true,
+		                "locked_temp_min_f": 65,
+		                "locked_temp_max_f": 75,
+		                "locked_temp_min_c": 18.5,
+		                "locked_temp_max_c": 24,
+		                "where_id": "d6reb_OZTM...",
+		                "where_name": "Hallway"
 		            }
 		        },
 		        "smoke_co_alarms": {
@@ -126,7 +168,53 @@ func combinedJSON() []byte {
 		                "battery_health": "ok",
 		                "co_alarm_state": "ok",
 		                "smoke_alarm_state": "ok",
-		                "ui_color_state": "gray"
+		                "ui_color_state": "gray",
+		                "is_manual_test_active": true,
+		                "last_manual_test_time": "2014-03-02T23:20:19+00:00",
+		                "where_id": "d6reb_OZTM...",
+		                "where_name": "Hallway"
+		            }
+		        },
+		        "cameras": {
+		            "awJo6rHcRE3Sr2M1TAvrBGYSb2ZgqtLXJFZI5HyOdvKIj": {
+		                "device_id": "awJo6rHcRE3Sr2M1TAvrBGYSb2ZgqtLXJFZI5HyOdvKIj",
+		                "software_version": "4.0",
+		                "structure_id": "VqFabWH21nwVyd4RWgJgNb292wa7hG_dUwo2i2SG7j3-BOLY0BA4sw",
+		                "where_id": "d6reb_OZTM...",
+		                "where_name": "Hallway",
+		                "name": "Hallway (upstairs)",
+		                "name_long": "Hallway Camera (upstairs)",
+		                "is_online": true,
+		                "is_streaming": true,
+		                "is_audio_input_enabled": true,
+		                "last_is_online_change": "2015-12-29T18:42:00.000Z",
+		                "is_video_history_enabled": true,
+		                "web_url": "https://home.nest.com/cameras/awJo6rH?auth=c.9i8pWrv...",
+		                "app_url": "nestmobile://cameras/awJo6rH?auth=c.9i8pWrv...",
+		                "is_public_share_enabled": true,
+		                "activity_zones": [
+		                    {
+		                        "name": "Walkway",
+		                        "id": 244083
+		                    }
+		                ],
+		                "public_share_url": "https://video.nest.com/live/STRING1?autoplay=1",
+		                "snapshot_url": "https://developer.nest.com/simulator/api/v1/nest/devices/camera/snapshot",
+		                "last_event": {
+		                    "has_sound": true,
+		                    "has_motion": true,
+		                    "has_person": false,
+		                    "start_time": "2016-12-29T00:00:00.000Z",
+		                    "end_time": "2016-12-29T00:00:30.000Z",
+		                    "urls_expire_time": "2016-12-29T18:42:00.000Z",
+		                    "web_url": "https://home.nest.com/cameras/awJo6rH/cuepoints/1234?auth=c.9i8pWrv...",
+		                    "app_url": "nestmobile://cameras/awJo6rH/cuepoints/1234?auth=c.9i8pWrv...",
+		                    "image_url": "https://developer.nest.com/simulator/api/v1/nest/devices/camera/image",
+		                    "animated_image_url": "https://developer.nest.com/simulator/api/v1/nest/devices/camera/animated",
+		                    "activity_zone_ids": [
+		                        "244083"
+		                    ]
+		                }
 		            }
 		        }
 		    },
@@ -139,12 +227,33 @@ func combinedJSON() []byte {
 		            "smoke_co_alarms": [
 		                "RTMTKxsQTCxzVcsySOHPxKoF4OyCifrs"
 		            ],
+		            "cameras": [
+		                "awJo6rHcRE3Sr2M1TAvrBGYSb2ZgqtLXJFZI5HyOdvKIj"
+		            ],
 		            "away": "home",
 		            "name": "Home",
 		            "country_code": "US",
+		            "postal_code": "94304",
 		            "peak_period_start_time": "2014-03-10T23:10:12+00:00",
 		            "peak_period_end_time": "2014-03-10T23:14:19+00:00",
 		            "time_zone": "America/Los_Angeles",
+		            "rhr_enrollment": true,
+		            "co_alarm_state": "ok",
+		            "smoke_alarm_state": "ok",
+		            "wwn_security_state": "ok",
+		            "devices": {
+		                "$company": {
+		                    "$product_type": [
+		                        "CPMEMSnC48JlSAHjQIp-aHI72IjLYHK_ul_c54UFb8CmPXNj4ixLbg"
+		                    ]
+		                }
+		            },
+		            "wheres": {
+		                "d6reb_OZTM...": {
+		                    "where_id": "d6reb_OZTM...",
+		                    "name": "Hallway"
+		                }
+		            },
 		            "eta": {
 		                "trip_id": "myTripHome1024",
 		                "estimated_arrival_window_begin": "2014-07-04T10:48:11+00:00",
@@ -162,3 +271,23 @@ func accessJSON() []byte {
 			"expires_in": 315360000
 		}`)
 }
+
+func TestClientNotMarshalled(t *testing.T) {
+	Convey("Marshalling devices and structures should leave out the client and its secrets", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		objects := []interface{}{
+			&Thermostat{DeviceID: "t1", Client: client},
+			&SmokeCoAlarm{DeviceID: "a1", Client: client},
+			&Camera{DeviceID: "c1", Client: client},
+			&Structure{StructureID: "s1", Client: client},
+		}
+		for _, object := range objects {
+			data, err := json.Marshal(object)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldNotContainSubstring, Token)
+			So(string(data), ShouldNotContainSubstring, ClientSecret)
+			So(string(data), ShouldNotContainSubstring, "Client")
+		}
+	})
+}
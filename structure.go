@@ -2,7 +2,6 @@ package nest
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -58,80 +57,163 @@ func (c *Client) StructuresStream(callback func(structures map[string]*Structure
 }
 
 /*
-SetAway sets the away status of a structure
+SetAway sets the away status of a structure to home or away. Auto-away is only set by Nest itself.
 https://developer.nest.com/documentation/api#away
 
 	s.SetAway(nest.Away)
 */
-func (s *Structure) SetAway(mode int) *APIError {
-	requestMode := make(map[string]string)
-	switch mode {
-	case Home:
-		requestMode["away"] = "home"
-	case Away:
-		requestMode["away"] = "away"
-	case AutoAway:
-		requestMode["away"] = "auto-away"
-	default:
-		return generateAPIError("Invalid Away requested - must be home, away or auto-away")
+func (s *Structure) SetAway(mode AwayMode) *APIError {
+	if mode != Home && mode != Away {
+		return generateAPIError("Invalid Away requested - must be home or away")
 	}
+	requestMode := make(map[string]AwayMode)
+	requestMode["away"] = mode
 	body, _ := json.Marshal(requestMode)
 	return s.setStructure(body)
 }
 
 /*
-SetETA sets the ETA for the Nest API
+SetETA sets the ETA of a new trip for the Nest API. The arrival window must not have begun yet.
 https://developer.nest.com/documentation/eta-reference
 
+	s.SetETA("trip-1234", time.Now().Add(20*time.Minute), time.Now().Add(30*time.Minute))
 */
 func (s *Structure) SetETA(tripID string, begin time.Time, end time.Time) *APIError {
 	apiErr := checkTimes(begin, end)
 	if apiErr != nil {
 		return apiErr
 	}
+	return s.putETA(tripID, begin, end)
+}
+
+/*
+UpdateETA changes the arrival window of a trip already sent with SetETA, reusing its trip ID.
+The window may have begun, but must not have ended.
+https://developer.nest.com/documentation/eta-reference
+
+	s.UpdateETA("trip-1234", time.Now().Add(-5*time.Minute), time.Now().Add(5*time.Minute))
+*/
+func (s *Structure) UpdateETA(tripID string, begin time.Time, end time.Time) *APIError {
+	apiErr := checkWindow(begin, end)
+	if apiErr != nil {
+		return apiErr
+	}
+	return s.putETA(tripID, begin, end)
+}
+
+/*
+CancelETA cancels a trip by sending zeroed arrival times for its trip ID
+https://developer.nest.com/documentation/eta-reference
+
+	s.CancelETA("trip-1234")
+*/
+func (s *Structure) CancelETA(tripID string) *APIError {
+	if tripID == "" {
+		return generateError("eta_error", "A trip ID is required")
+	}
+	request := make(map[string]interface{})
+	request["trip_id"] = tripID
+	request["estimated_arrival_window_begin"] = 0
+	request["estimated_arrival_window_end"] = 0
+	data, _ := json.Marshal(request)
+	_, apiErr := s.Client.put("/structures/"+s.StructureID+"/eta.json", data)
+	if apiErr != nil {
+		return apiErr
+	}
+	if s.ETA != nil && s.ETA.TripID == tripID {
+		s.ETA = nil
+	}
+	return nil
+}
+
+// putETA sends the ETA to the Nest REST API and records it on the structure
+func (s *Structure) putETA(tripID string, begin time.Time, end time.Time) *APIError {
+	if tripID == "" {
+		return generateError("eta_error", "A trip ID is required")
+	}
 	eta := &ETA{
-		TripID: tripID,
+		TripID:                      tripID,
 		EstimatedArrivalWindowBegin: begin,
 		EstimatedArrivalWindowEnd:   end,
 	}
 	data, _ := json.Marshal(eta)
-	req, _ := http.NewRequest("PUT", s.Client.RedirectURL+"/structures/"+s.StructureID+"/eta.json?auth="+s.Client.Token, bytes.NewBuffer(data))
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		apiError := &APIError{
-			Error:       "http_error",
-			Description: err.Error(),
+	_, apiErr := s.Client.put("/structures/"+s.StructureID+"/eta.json", data)
+	if apiErr != nil {
+		return apiErr
+	}
+	s.ETA = eta
+	return nil
+}
+
+/*
+ThermostatsIn returns the structure's thermostats found in devices, which may be nil
+
+	thermostats := structure.ThermostatsIn(devices)
+*/
+func (s *Structure) ThermostatsIn(devices *Devices) []*Thermostat {
+	thermostats := []*Thermostat{}
+	if devices == nil {
+		return thermostats
+	}
+	for _, id := range s.Thermostats {
+		if thermostat, ok := devices.Thermostats[id]; ok {
+			thermostats = append(thermostats, thermostat)
 		}
-		return apiError
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode != 200 {
-		apiError := &APIError{}
-		json.Unmarshal(body, apiError)
-		apiError = generateAPIError(apiError.Error)
-		apiError.Status = resp.Status
-		apiError.StatusCode = resp.StatusCode
-		return apiError
+	return thermostats
+}
+
+/*
+SmokeCoAlarmsIn returns the structure's smokecoalarms found in devices, which may be nil
+
+	alarms := structure.SmokeCoAlarmsIn(devices)
+*/
+func (s *Structure) SmokeCoAlarmsIn(devices *Devices) []*SmokeCoAlarm {
+	alarms := []*SmokeCoAlarm{}
+	if devices == nil {
+		return alarms
 	}
-	return nil
+	for _, id := range s.SmokeCoAlarms {
+		if alarm, ok := devices.SmokeCoAlarms[id]; ok {
+			alarms = append(alarms, alarm)
+		}
+	}
+	return alarms
+}
+
+/*
+CamerasIn returns the structure's cameras found in devices, which may be nil
+
+	cameras := structure.CamerasIn(devices)
+*/
+func (s *Structure) CamerasIn(devices *Devices) []*Camera {
+	cameras := []*Camera{}
+	if devices == nil {
+		return cameras
+	}
+	for _, id := range s.Cameras {
+		if camera, ok := devices.Cameras[id]; ok {
+			cameras = append(cameras, camera)
+		}
+	}
+	return cameras
 }
 
-// checkTimes ensure the times provided are set properly for the Nest API
+// checkTimes ensure the times provided for a new trip are set properly for the Nest API
 func checkTimes(begin time.Time, end time.Time) *APIError {
 	if begin.Before(time.Now()) {
-		apiError := &APIError{
-			Error:       "eta_error",
-			Description: "The begin time must be greater than the time now",
-		}
-		return apiError
+		return generateError("eta_error", "The begin time must be greater than the time now")
 	}
+	return checkWindow(begin, end)
+}
+
+// checkWindow ensures the arrival window is in order and has not already passed
+func checkWindow(begin time.Time, end time.Time) *APIError {
 	if end.Before(begin) {
-		apiError := &APIError{
-			Error:       "eta_error",
-			Description: "The end time must be greater than the begin time",
-		}
-		return apiError
+		return generateError("eta_error", "The end time must be greater than the begin time")
+	}
+	if end.Before(time.Now()) {
+		return generateError("eta_error", "The end time must be greater than the time now")
 	}
 	return nil
 }
@@ -171,8 +253,8 @@ func (c *Client) watchStructuresStream(resp *http.Response, callback func(struct
 func (c *Client) getStructures(action int) (*http.Response, error) {
 	if c.RedirectURL == "" {
 		req, _ := http.NewRequest("GET", c.APIURL+"/structures.json?auth="+c.Token, nil)
-		resp, err := http.DefaultClient.Do(req)
-		if resp.Request.URL != nil {
+		resp, err := c.httpClient().Do(req)
+		if err == nil && resp.Request.URL != nil {
 			c.RedirectURL = resp.Request.URL.Scheme + "://" + resp.Request.URL.Host
 		}
 		return resp, err
@@ -182,37 +264,25 @@ func (c *Client) getStructures(action int) (*http.Response, error) {
 	if action == Stream {
 		req.Header.Set("Accept", "text/event-stream")
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient().Do(req)
 	return resp, err
 }
 
-// setStructure sends the request to the Nest REST API
+// setStructure sends the request to the Nest REST API and applies the result to the structure
 func (s *Structure) setStructure(body []byte) *APIError {
-	url := s.Client.RedirectURL + "/structures/" + s.StructureID + "?auth=" + s.Client.Token
-	client := &http.Client{}
-	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	if err != nil {
-		apiError := &APIError{
-			Error:       "http_error",
-			Description: err.Error(),
+	response, apiErr := s.Client.put("/structures/"+s.StructureID, body)
+	if apiErr != nil {
+		return apiErr
+	}
+	json.Unmarshal(response, s)
+	if s.Client.ConfirmTimeout > 0 {
+		confirmed, apiErr := s.Client.confirm("/structures.json", []string{s.StructureID}, body)
+		if apiErr != nil {
+			return apiErr
 		}
-		return apiError
+		json.Unmarshal(confirmed, s)
 	}
-	body, _ = ioutil.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		structure := &Structure{}
-		json.Unmarshal(body, structure)
-		return nil
-	}
-	apiError := &APIError{}
-	json.Unmarshal(body, apiError)
-	apiError = generateAPIError(apiError.Error)
-	apiError.Status = resp.Status
-	apiError.StatusCode = resp.StatusCode
-	return apiError
+	return nil
 }
 
 // associateClientToStructures ensures each structure knows its client details
@@ -1,6 +1,7 @@
 package nest
 
 import (
+	"encoding/json"
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
 	"time"
@@ -32,10 +33,21 @@ func TestSetAway(t *testing.T) {
 		Convey("When setting to away", func() {
 			err := structures["h68sn..."].SetAway(Away)
 			So(err, ShouldBeNil)
+			So(structures["h68sn..."].Away, ShouldEqual, Away)
+		})
+		Convey("When waiting for the stream to confirm the away status", func() {
+			client.ConfirmTimeout = 2 * time.Second
+			structure := &Structure{StructureID: "s1234", Away: Home, Client: client}
+			err := structure.SetAway(Away)
+			So(err, ShouldBeNil)
+			So(structure.Away, ShouldEqual, Away)
+			So(structure.Name, ShouldEqual, "Miramar")
 		})
 		Convey("When setting an invalid away status", func() {
-			err := structures["h68sn..."].SetAway(2000)
-			So(err.Description, ShouldEqual, "Invalid Away requested - must be home, away or auto-away")
+			err := structures["h68sn..."].SetAway(AwayMode("vacation"))
+			So(err.Description, ShouldEqual, "Invalid Away requested - must be home or away")
+			err = structures["h68sn..."].SetAway(AutoAway)
+			So(err.Description, ShouldEqual, "Invalid Away requested - must be home or away")
 		})
 	})
 }
@@ -64,6 +76,36 @@ func TestStructuresStream(t *testing.T) {
 	})
 }
 
+func TestStructureDevices(t *testing.T) {
+	Convey("Given a structure and its devices", t, func() {
+		combined := &Combined{}
+		json.Unmarshal(combinedJSON(), combined)
+		structure := combined.Structures["VqFabWH21nwVyd4RWgJgNb292wa7hG_dUwo2i2SG7j3-BOLY0BA4sw"]
+
+		Convey("We should get its devices", func() {
+			thermostats := structure.ThermostatsIn(combined.Devices)
+			So(len(thermostats), ShouldEqual, 1)
+			So(thermostats[0].DeviceID, ShouldEqual, "peyiJNo0IldT2YlIVtYaGQ")
+			alarms := structure.SmokeCoAlarmsIn(combined.Devices)
+			So(alarms[0].DeviceID, ShouldEqual, "RTMTKxsQTCxzVcsySOHPxKoF4OyCifrs")
+			cameras := structure.CamerasIn(combined.Devices)
+			So(cameras[0].DeviceID, ShouldEqual, "awJo6rHcRE3Sr2M1TAvrBGYSb2ZgqtLXJFZI5HyOdvKIj")
+		})
+
+		Convey("Devices missing from the payload should be skipped", func() {
+			structure.Thermostats = append(structure.Thermostats, "missing")
+			So(len(structure.ThermostatsIn(combined.Devices)), ShouldEqual, 1)
+			So(structure.CamerasIn(&Devices{}), ShouldBeEmpty)
+		})
+
+		Convey("No devices should give no devices", func() {
+			So(structure.ThermostatsIn(nil), ShouldBeEmpty)
+			So(structure.SmokeCoAlarmsIn(nil), ShouldBeEmpty)
+			So(structure.CamerasIn(nil), ShouldBeEmpty)
+		})
+	})
+}
+
 func TestSetETA(t *testing.T) {
 	client := New(ClientID, State, ClientSecret, AuthorizationCode)
 	client.Authorize()
@@ -86,6 +128,41 @@ func TestSetETA(t *testing.T) {
 		Convey("When we set an ETA all should be well", func() {
 			err := structures["h68sn..."].SetETA("foobar-trip", time.Now().Add(5*time.Minute), time.Now().Add(5*time.Minute))
 			So(err, ShouldBeNil)
+			So(structures["h68sn..."].ETA.TripID, ShouldEqual, "foobar-trip")
+		})
+		Convey("When we provide no trip ID we should get an error", func() {
+			err := structures["h68sn..."].SetETA("", time.Now().Add(5*time.Minute), time.Now().Add(10*time.Minute))
+			So(err.Description, ShouldEqual, "A trip ID is required")
+		})
+	})
+}
+
+func TestUpdateETA(t *testing.T) {
+	client := New(ClientID, State, ClientSecret, AuthorizationCode)
+	client.Token = Token
+	client.APIURL = ts.URL
+	structures, _ := client.Structures()
+	structure := structures["h68sn..."]
+
+	Convey("When updating an ETA", t, func() {
+		Convey("When the window has begun it should still be accepted", func() {
+			err := structure.UpdateETA("foobar-trip", time.Now().Add(-5*time.Minute), time.Now().Add(5*time.Minute))
+			So(err, ShouldBeNil)
+			So(structure.ETA.TripID, ShouldEqual, "foobar-trip")
+		})
+		Convey("When the window has ended we should get an error", func() {
+			err := structure.UpdateETA("foobar-trip", time.Now().Add(-10*time.Minute), time.Now().Add(-5*time.Minute))
+			So(err.Description, ShouldEqual, "The end time must be greater than the time now")
+		})
+		Convey("When we cancel the ETA it should be cleared", func() {
+			structure.UpdateETA("foobar-trip", time.Now(), time.Now().Add(5*time.Minute))
+			err := structure.CancelETA("foobar-trip")
+			So(err, ShouldBeNil)
+			So(structure.ETA, ShouldBeNil)
+		})
+		Convey("When we cancel without a trip ID we should get an error", func() {
+			err := structure.CancelETA("")
+			So(err.Description, ShouldEqual, "A trip ID is required")
 		})
 	})
 }
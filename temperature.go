@@ -0,0 +1,233 @@
+package nest
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// Scale represents a temperature scale as used by the Nest API
+// https://developer.nest.com/documentation/api#temperature_scale
+type Scale string
+
+const (
+	// Fahrenheit is the "F" temperature scale
+	Fahrenheit Scale = "F"
+	// Celsius is the "C" temperature scale
+	Celsius Scale = "C"
+)
+
+// String returns the Scale as sent to the Nest API
+func (s Scale) String() string {
+	return string(s)
+}
+
+// Valid reports whether the Scale is one known to the library
+func (s Scale) Valid() bool {
+	return s == Fahrenheit || s == Celsius
+}
+
+// Temperature represents a temperature value along with the scale it is in
+type Temperature struct {
+	Value float64
+	Scale Scale
+}
+
+/*
+TempF creates a Temperature in farenheit
+
+	t.SetTarget(nest.TempF(72))
+*/
+func TempF(value float64) Temperature {
+	return Temperature{Value: value, Scale: Fahrenheit}
+}
+
+/*
+TempC creates a Temperature in celcius
+
+	t.SetTarget(nest.TempC(21.5))
+*/
+func TempC(value float64) Temperature {
+	return Temperature{Value: value, Scale: Celsius}
+}
+
+// F returns the value of the temperature in farenheit
+func (t Temperature) F() float64 {
+	if t.Scale == Celsius {
+		return t.Value*9/5 + 32
+	}
+	return t.Value
+}
+
+// C returns the value of the temperature in celcius
+func (t Temperature) C() float64 {
+	if t.Scale == Fahrenheit {
+		return (t.Value - 32) * 5 / 9
+	}
+	return t.Value
+}
+
+// In converts the temperature to the given scale
+func (t Temperature) In(scale Scale) Temperature {
+	if scale == Celsius {
+		return TempC(t.C())
+	}
+	return TempF(t.F())
+}
+
+// Round rounds the temperature to what the Nest API accepts, whole degrees farenheit or half degrees celcius
+func (t Temperature) Round() Temperature {
+	if t.Scale == Celsius {
+		t.Value = math.Round(t.Value*2) / 2
+	} else {
+		t.Value = math.Round(t.Value)
+	}
+	return t
+}
+
+// String formats the rounded temperature along with its scale, such as 72°F or 21.5°C
+func (t Temperature) String() string {
+	return strconv.FormatFloat(t.Round().Value, 'f', -1, 64) + "°" + string(t.Scale)
+}
+
+/*
+SetTarget sets the thermostat to an intended temp, in whichever scale the temperature is given.
+The temperature must be in range before it is rounded to what the Nest API accepts.
+https://developer.nest.com/documentation/api#target_temperature_f
+https://developer.nest.com/documentation/api#target_temperature_c
+
+	t.SetTarget(nest.TempC(21.5))
+*/
+func (t *Thermostat) SetTarget(temp Temperature) *APIError {
+	apiErr := checkRange(temp)
+	if apiErr != nil {
+		return apiErr
+	}
+	temp, apiErr = t.checkLocked(temp)
+	if apiErr != nil {
+		return apiErr
+	}
+	apiErr = t.checkTargetMode()
+	if apiErr != nil {
+		return apiErr
+	}
+	body := temperatureRequest(map[string]Temperature{"target_temperature": temp.Round()})
+	return t.setThermostat(body)
+}
+
+/*
+SetTargetHighLow sets the high and low target temps when HvacMode is HeatCool, in the scale of the high temperature.
+Both must be in range before they are rounded, and at least the minimum spread apart once rounded.
+https://developer.nest.com/documentation/api#target_temperature_high_f
+https://developer.nest.com/documentation/api#target_temperature_low_f
+
+	t.SetTargetHighLow(nest.TempF(75), nest.TempF(65))
+*/
+func (t *Thermostat) SetTargetHighLow(high Temperature, low Temperature) *APIError {
+	low = low.In(high.Scale)
+	if high.Value < low.Value {
+		return generateError(RangeError, "The high temperature must be greater than the low temperature")
+	}
+	for _, temp := range []Temperature{high, low} {
+		apiErr := checkRange(temp)
+		if apiErr != nil {
+			return apiErr
+		}
+	}
+	high, apiErr := t.checkLocked(high)
+	if apiErr != nil {
+		return apiErr
+	}
+	low, apiErr = t.checkLocked(low)
+	if apiErr != nil {
+		return apiErr
+	}
+	high, low = high.Round(), low.Round()
+	if high.Scale == Celsius && high.Value-low.Value < minHeatCoolSpreadC {
+		return generateError(RangeError, "The high and low temperatures must be at least 1.5 Celcius apart")
+	}
+	if high.Scale == Fahrenheit && high.Value-low.Value < minHeatCoolSpreadF {
+		return generateError(RangeError, "The high and low temperatures must be at least 3 Farenheit apart")
+	}
+	apiErr = t.checkHighLowMode()
+	if apiErr != nil {
+		return apiErr
+	}
+	body := temperatureRequest(map[string]Temperature{
+		"target_temperature_high": high,
+		"target_temperature_low":  low,
+	})
+	return t.setThermostat(body)
+}
+
+// Ambient returns the ambient temperature in the thermostat's scale
+func (t *Thermostat) Ambient() Temperature {
+	return t.temperature(t.AmbientTemperatureF, t.AmbientTemperatureC)
+}
+
+// Target returns the target temperature in the thermostat's scale
+func (t *Thermostat) Target() Temperature {
+	return t.temperature(t.TargetTemperatureF, t.TargetTemperatureC)
+}
+
+// TargetHigh returns the high target temperature in the thermostat's scale
+func (t *Thermostat) TargetHigh() Temperature {
+	return t.temperature(t.TargetTemperatureHighF, t.TargetTemperatureHighC)
+}
+
+// TargetLow returns the low target temperature in the thermostat's scale
+func (t *Thermostat) TargetLow() Temperature {
+	return t.temperature(t.TargetTemperatureLowF, t.TargetTemperatureLowC)
+}
+
+// FormatTemperature formats a temperature in the thermostat's scale
+func (t *Thermostat) FormatTemperature(temp Temperature) string {
+	return temp.In(t.scale()).String()
+}
+
+// temperature picks the farenheit or celcius field value based on the thermostat's scale
+func (t *Thermostat) temperature(f int, c float32) Temperature {
+	if t.scale() == Celsius {
+		return TempC(float64(c))
+	}
+	return TempF(float64(f))
+}
+
+// scale returns the thermostat's scale, defaulting to farenheit
+func (t *Thermostat) scale() Scale {
+	if t.TemperatureScale == Celsius {
+		return Celsius
+	}
+	return Fahrenheit
+}
+
+// checkRange ensures a temperature is within the limits of the Nest API
+func checkRange(temp Temperature) *APIError {
+	switch temp.Scale {
+	case Fahrenheit:
+		if temp.Value < 50 || temp.Value > 90 {
+			return generateError(RangeError, "Temperature must be between 50 and 90 Farenheit")
+		}
+	case Celsius:
+		if temp.Value < 9 || temp.Value > 32 {
+			return generateError(RangeError, "Temperature must be between 9 and 32 Celcius")
+		}
+	default:
+		return generateError(RangeError, "Temperature scale must be F or C")
+	}
+	return nil
+}
+
+// temperatureRequest builds a request body, choosing the _f or _c field for each temperature by its scale
+func temperatureRequest(temps map[string]Temperature) []byte {
+	request := make(map[string]interface{})
+	for field, temp := range temps {
+		if temp.Scale == Celsius {
+			request[field+"_c"] = temp.Value
+		} else {
+			request[field+"_f"] = int(temp.Value)
+		}
+	}
+	body, _ := json.Marshal(request)
+	return body
+}
@@ -0,0 +1,90 @@
+package nest
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestTemperature(t *testing.T) {
+	Convey("When converting temperatures", t, func() {
+		So(TempC(20).F(), ShouldEqual, 68)
+		So(TempF(212).C(), ShouldEqual, 100)
+		So(TempF(72).In(Celsius).Scale, ShouldEqual, Celsius)
+		So(TempC(21.5).In(Fahrenheit).Round().Value, ShouldEqual, 71)
+	})
+
+	Convey("When rounding to what Nest accepts", t, func() {
+		So(TempF(71.6).Round().Value, ShouldEqual, 72)
+		So(TempC(21.3).Round().Value, ShouldEqual, 21.5)
+		So(TempC(21.2).Round().Value, ShouldEqual, 21)
+	})
+
+	Convey("When formatting temperatures", t, func() {
+		So(TempF(71.6).String(), ShouldEqual, "72°F")
+		So(TempC(21.4).String(), ShouldEqual, "21.5°C")
+		thermostat := &Thermostat{TemperatureScale: Celsius}
+		So(thermostat.FormatTemperature(TempF(68)), ShouldEqual, "20°C")
+	})
+
+	Convey("When reading temperatures from a thermostat", t, func() {
+		thermostat := &Thermostat{
+			TemperatureScale:    Celsius,
+			AmbientTemperatureF: 72,
+			AmbientTemperatureC: 21.5,
+			TargetTemperatureF:  70,
+			TargetTemperatureC:  21,
+		}
+		So(thermostat.Ambient(), ShouldResemble, TempC(21.5))
+		So(thermostat.Target(), ShouldResemble, TempC(21))
+		thermostat.TemperatureScale = Fahrenheit
+		So(thermostat.Ambient(), ShouldResemble, TempF(72))
+	})
+
+	Convey("When building a request the field should follow the scale", t, func() {
+		request := make(map[string]interface{})
+		json.Unmarshal(temperatureRequest(map[string]Temperature{"target_temperature": TempC(21.5)}), &request)
+		So(request["target_temperature_c"], ShouldEqual, 21.5)
+		request = make(map[string]interface{})
+		json.Unmarshal(temperatureRequest(map[string]Temperature{"target_temperature": TempF(72)}), &request)
+		So(request["target_temperature_f"], ShouldEqual, 72)
+	})
+
+	Convey("When setting temperatures", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.APIURL = ts.URL
+		devices, _ := client.Devices()
+		client.RedirectURL = ts.URL
+		thermostat := devices.Thermostats["z1234"]
+		Convey("The temperature should be rounded and sent in its scale", func() {
+			err := thermostat.SetTarget(TempC(28.4))
+			So(err, ShouldBeNil)
+			So(thermostat.TargetTemperatureC, ShouldEqual, 28.5)
+		})
+		Convey("An out of range temperature should be rejected", func() {
+			err := thermostat.SetTarget(TempF(95))
+			So(err.Description, ShouldEqual, "Temperature must be between 50 and 90 Farenheit")
+			err = thermostat.SetTarget(Temperature{Value: 70})
+			So(err.Description, ShouldEqual, "Temperature scale must be F or C")
+		})
+		Convey("A temperature just out of range should be rejected before it is rounded", func() {
+			err := thermostat.SetTarget(TempF(90.4))
+			So(err.Description, ShouldEqual, "Temperature must be between 50 and 90 Farenheit")
+			err = thermostat.SetTarget(TempC(8.8))
+			So(err.Description, ShouldEqual, "Temperature must be between 9 and 32 Celcius")
+			thermostat.HvacMode = HeatCool
+			err = thermostat.SetTargetHighLow(TempF(90.4), TempF(70))
+			So(err.Error, ShouldEqual, RangeError)
+			err = thermostat.SetTargetHighLow(TempC(25), TempC(8.8))
+			So(err.Description, ShouldEqual, "Temperature must be between 9 and 32 Celcius")
+		})
+		Convey("High and low temperatures in different scales should be converted", func() {
+			thermostat.HvacMode = HeatCool
+			err := thermostat.SetTargetHighLow(TempF(75), TempC(18))
+			So(err, ShouldBeNil)
+			err = thermostat.SetTargetHighLow(TempF(70), TempC(20.5))
+			So(err.Error, ShouldEqual, RangeError)
+		})
+	})
+}
@@ -1,10 +1,20 @@
 package nest
 
 import (
-	"bytes"
 	"encoding/json"
-	"io/ioutil"
-	"net/http"
+)
+
+const (
+	// ModeError is the APIError.Error for writes the current HvacMode does not allow
+	ModeError = "mode_error"
+	// CapabilityError is the APIError.Error for writes the thermostat hardware does not support
+	CapabilityError = "capability_error"
+	// RangeError is the APIError.Error for temperatures the Nest API would reject
+	RangeError = "range_error"
+	// minHeatCoolSpreadF is the smallest gap Nest allows between the high and low targets
+	minHeatCoolSpreadF = 3
+	// minHeatCoolSpreadC is the smallest gap Nest allows between the high and low targets
+	minHeatCoolSpreadC = 1.5
 )
 
 /*
@@ -14,32 +24,81 @@ https://developer.nest.com/documentation/api#fan_timer_active
 	t.SetFanTimerActive(true)
 */
 func (t *Thermostat) SetFanTimerActive(setting bool) *APIError {
+	if !t.HasFan {
+		return generateError(CapabilityError, "Thermostat does not have a fan")
+	}
 	request := make(map[string]bool)
 	request["fan_timer_active"] = setting
 	body, _ := json.Marshal(request)
 	return t.setThermostat(body)
 }
 
+/*
+SetFanTimerDuration sets how many minutes the fan runs when the fan timer is activated
+https://developer.nest.com/documentation/api#fan_timer_duration
+
+	t.SetFanTimerDuration(30)
+*/
+func (t *Thermostat) SetFanTimerDuration(minutes int) *APIError {
+	if !t.HasFan {
+		return generateError(CapabilityError, "Thermostat does not have a fan")
+	}
+	switch minutes {
+	case 15, 30, 45, 60, 120, 240, 480, 720, 960:
+	default:
+		return generateError(RangeError, "Fan timer duration must be 15, 30, 45, 60, 120, 240, 480, 720 or 960 minutes")
+	}
+	request := make(map[string]int)
+	request["fan_timer_duration"] = minutes
+	body, _ := json.Marshal(request)
+	return t.setThermostat(body)
+}
+
+/*
+SetLabel sets the custom label shown in the thermostat's name
+https://developer.nest.com/documentation/api#label
+
+	t.SetLabel("Guest Room")
+*/
+func (t *Thermostat) SetLabel(label string) *APIError {
+	request := make(map[string]string)
+	request["label"] = label
+	body, _ := json.Marshal(request)
+	return t.setThermostat(body)
+}
+
+/*
+SetTemperatureScale sets the scale the thermostat displays temperatures in
+https://developer.nest.com/documentation/api#temperature_scale
+
+	t.SetTemperatureScale(nest.Celsius)
+*/
+func (t *Thermostat) SetTemperatureScale(scale Scale) *APIError {
+	if !scale.Valid() {
+		return generateAPIError("Invalid temperature scale requested - must be F or C")
+	}
+	request := make(map[string]Scale)
+	request["temperature_scale"] = scale
+	body, _ := json.Marshal(request)
+	return t.setThermostat(body)
+}
+
 /*
 SetHvacMode sets the HvacMode when a thermostat may heat and cool
 https://developer.nest.com/documentation/api#hvac_mode
 
 	t.SetHvacMode(Cool)
 */
-func (t *Thermostat) SetHvacMode(mode int) *APIError {
-	requestMode := make(map[string]string)
-	switch mode {
-	case Cool:
-		requestMode["hvac_mode"] = "cool"
-	case Heat:
-		requestMode["hvac_mode"] = "heat"
-	case HeatCool:
-		requestMode["hvac_mode"] = "heat-cool"
-	case Off:
-		requestMode["hvac_mode"] = "off"
-	default:
-		return generateAPIError("Invalid HvacMode requested - must be cool, heat, heat-cool or off")
+func (t *Thermostat) SetHvacMode(mode HvacMode) *APIError {
+	if !mode.Valid() {
+		return generateAPIError("Invalid HvacMode requested - must be cool, heat, heat-cool, eco or off")
+	}
+	apiErr := t.checkCapabilities(mode)
+	if apiErr != nil {
+		return apiErr
 	}
+	requestMode := make(map[string]HvacMode)
+	requestMode["hvac_mode"] = mode
 	body, _ := json.Marshal(requestMode)
 	return t.setThermostat(body)
 }
@@ -51,13 +110,7 @@ https://developer.nest.com/documentation/api#target_temperature_c
 	t.SetTargetTempC(28.5)
 */
 func (t *Thermostat) SetTargetTempC(temp float32) *APIError {
-	if temp < 9 || temp > 32 {
-		return generateAPIError("Temperature must be between 9 and 32 Celcius")
-	}
-	tempRequest := make(map[string]float32)
-	tempRequest["target_temperature_c"] = temp
-	body, _ := json.Marshal(tempRequest)
-	return t.setThermostat(body)
+	return t.SetTarget(TempC(float64(temp)))
 }
 
 /*
@@ -67,13 +120,7 @@ https://developer.nest.com/documentation/api#target_temperature_f
 	t.SetTargetTempF(78)
 */
 func (t *Thermostat) SetTargetTempF(temp int) *APIError {
-	if temp < 50 || temp > 90 {
-		return generateAPIError("Temperature must be between 50 and 90 Farenheit")
-	}
-	request := make(map[string]int)
-	request["target_temperature_f"] = temp
-	body, _ := json.Marshal(request)
-	return t.setThermostat(body)
+	return t.SetTarget(TempF(float64(temp)))
 }
 
 /*
@@ -81,17 +128,10 @@ SetTargetTempHighLowC sets the high target temp in celcius when HvacMode is Heat
 https://developer.nest.com/documentation/api#target_temperature_high_c
 https://developer.nest.com/documentation/api#target_temperature_low_c
 
-	t.SetTargetTempHighLowF(75, 65)
+	t.SetTargetTempHighLowC(24, 18.5)
 */
 func (t *Thermostat) SetTargetTempHighLowC(high float32, low float32) *APIError {
-	if high < low {
-		return generateAPIError("The high temperature must be greater than the low temperature")
-	}
-	request := make(map[string]float32)
-	request["target_temperature_high_c"] = high
-	request["target_temperature_low_c"] = low
-	body, _ := json.Marshal(request)
-	return t.setThermostat(body)
+	return t.SetTargetHighLow(TempC(float64(high)), TempC(float64(low)))
 }
 
 /*
@@ -102,50 +142,72 @@ https://developer.nest.com/documentation/api#target_temperature_low_f
 	t.SetTargetTempHighLowF(75, 65)
 */
 func (t *Thermostat) SetTargetTempHighLowF(high int, low int) *APIError {
-	if high < low {
-		return generateAPIError("The high temperature must be greater than the low temperature")
-	}
-	request := make(map[string]int)
-	request["target_temperature_high_f"] = high
-	request["target_temperature_low_f"] = low
-	body, _ := json.Marshal(request)
-	return t.setThermostat(body)
+	return t.SetTargetHighLow(TempF(float64(high)), TempF(float64(low)))
 }
 
-// setThermostat sends the request to the Nest REST API
+// setThermostat sends the request to the Nest REST API and applies the result to the thermostat
 func (t *Thermostat) setThermostat(body []byte) *APIError {
-	url := t.Client.RedirectURL + "/devices/thermostats/" + t.DeviceID + "?auth=" + t.Client.Token
-	client := &http.Client{}
-	req, _ := http.NewRequest("PUT", url, bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	if err != nil {
-		apiError := &APIError{
-			Error:       "http_error",
-			Description: err.Error(),
+	response, apiErr := t.Client.put("/devices/thermostats/"+t.DeviceID, body)
+	if apiErr != nil {
+		return apiErr
+	}
+	json.Unmarshal(response, t)
+	if t.Client.ConfirmTimeout > 0 {
+		confirmed, apiErr := t.Client.confirm("/devices.json", []string{"thermostats", t.DeviceID}, body)
+		if apiErr != nil {
+			return apiErr
 		}
-		return apiError
+		json.Unmarshal(confirmed, t)
+	}
+	return nil
+}
+
+// checkCapabilities ensures the thermostat hardware supports the requested HvacMode
+func (t *Thermostat) checkCapabilities(mode HvacMode) *APIError {
+	switch {
+	case mode == Cool && !t.CanCool:
+		return generateError(CapabilityError, "Thermostat cannot cool")
+	case mode == Heat && !t.CanHeat:
+		return generateError(CapabilityError, "Thermostat cannot heat")
+	case mode == HeatCool && !(t.CanHeat && t.CanCool):
+		return generateError(CapabilityError, "Thermostat must be able to heat and cool to use heat-cool")
 	}
-	body, _ = ioutil.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		thermostat := &Thermostat{}
-		json.Unmarshal(body, thermostat)
-		return nil
+	return nil
+}
+
+// checkTargetMode ensures the current HvacMode accepts a single target temperature
+func (t *Thermostat) checkTargetMode() *APIError {
+	switch t.HvacMode {
+	case HeatCool:
+		return generateError(ModeError, "Cannot set the target temperature while HvacMode is heat-cool - set the high and low temperatures instead")
+	case Off:
+		return generateError(ModeError, "Cannot set the target temperature while HvacMode is off")
+	case Eco:
+		return generateError(ModeError, "Cannot set the target temperature while HvacMode is eco - leave eco first")
 	}
-	apiError := &APIError{}
-	json.Unmarshal(body, apiError)
-	apiError = generateAPIError(apiError.Error)
-	apiError.Status = resp.Status
-	apiError.StatusCode = resp.StatusCode
-	return apiError
+	return nil
 }
 
-// generateAPIError generates an error to return when an API call is invalid
-func generateAPIError(description string) *APIError {
-	apiError := &APIError{
-		Error:       "api_error",
+// checkHighLowMode ensures the current HvacMode accepts high and low target temperatures
+func (t *Thermostat) checkHighLowMode() *APIError {
+	if t.HvacMode == Eco {
+		return generateError(ModeError, "Cannot set the high and low temperatures while HvacMode is eco - leave eco first")
+	}
+	if t.HvacMode != HeatCool {
+		return generateError(ModeError, "Cannot set the high and low temperatures unless HvacMode is heat-cool")
+	}
+	return nil
+}
+
+// generateError generates an error of the given type to return when an API call is invalid
+func generateError(errorType string, description string) *APIError {
+	return &APIError{
+		Error:       errorType,
 		Description: description,
 	}
-	return apiError
+}
+
+// generateAPIError generates an error to return when an API call is invalid
+func generateAPIError(description string) *APIError {
+	return generateError("api_error", description)
 }
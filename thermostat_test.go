@@ -3,6 +3,7 @@ package nest
 import (
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
+	"time"
 )
 
 func TestFanTimeActive(t *testing.T) {
@@ -31,8 +32,8 @@ func TestSetHvacMode(t *testing.T) {
 		devices, _ := client.Devices()
 		client.RedirectURL = ts.URL
 		Convey("When an invalid mode given it should trow an error", func() {
-			err := devices.Thermostats["z1234"].SetHvacMode(2000)
-			So(err.Description, ShouldEqual, "Invalid HvacMode requested - must be cool, heat, heat-cool or off")
+			err := devices.Thermostats["z1234"].SetHvacMode(HvacMode("dry"))
+			So(err.Description, ShouldEqual, "Invalid HvacMode requested - must be cool, heat, heat-cool, eco or off")
 		})
 		Convey("When requesting HvacMode off", func() {
 			err := devices.Thermostats["z1234"].SetHvacMode(Off)
@@ -63,6 +64,7 @@ func TestTargetTemps(t *testing.T) {
 		devices, _ := client.Devices()
 		client.RedirectURL = ts.URL
 		Convey("When requesting to set a target high low temperature", func() {
+			devices.Thermostats["z1234"].HvacMode = HeatCool
 			Convey("When farenheit", func() {
 				err := devices.Thermostats["z1234"].SetTargetTempHighLowF(75, 65)
 				So(err, ShouldBeNil)
@@ -85,6 +87,114 @@ func TestTargetTemps(t *testing.T) {
 			So(err.Description, ShouldEqual, "Temperature must be between 50 and 90 Farenheit")
 			err = devices.Thermostats["z1234"].SetTargetTempC(8)
 			So(err.Description, ShouldEqual, "Temperature must be between 9 and 32 Celcius")
+			So(err.Error, ShouldEqual, RangeError)
+		})
+	})
+}
+
+func TestModeValidation(t *testing.T) {
+	Convey("When writing to a thermostat in a mode that does not allow it", t, func() {
+		thermostat := &Thermostat{DeviceID: "z1234", CanHeat: true, HvacMode: Heat}
+		Convey("Target temperatures should be rejected in heat-cool", func() {
+			thermostat.HvacMode = HeatCool
+			err := thermostat.SetTargetTempF(70)
+			So(err.Error, ShouldEqual, ModeError)
+			err = thermostat.SetTargetTempC(21)
+			So(err.Error, ShouldEqual, ModeError)
+		})
+		Convey("Target temperatures should be rejected when off", func() {
+			thermostat.HvacMode = Off
+			err := thermostat.SetTargetTempF(70)
+			So(err.Description, ShouldEqual, "Cannot set the target temperature while HvacMode is off")
+		})
+		Convey("High and low temperatures should be rejected outside heat-cool", func() {
+			err := thermostat.SetTargetTempHighLowF(75, 65)
+			So(err.Error, ShouldEqual, ModeError)
+			err = thermostat.SetTargetTempHighLowC(24, 18)
+			So(err.Error, ShouldEqual, ModeError)
+		})
+		Convey("High and low temperatures should be at least the minimum spread apart", func() {
+			thermostat.HvacMode = HeatCool
+			err := thermostat.SetTargetTempHighLowF(70, 68)
+			So(err.Description, ShouldEqual, "The high and low temperatures must be at least 3 Farenheit apart")
+			err = thermostat.SetTargetTempHighLowC(21, 20)
+			So(err.Description, ShouldEqual, "The high and low temperatures must be at least 1.5 Celcius apart")
+		})
+	})
+	Convey("When writing to a thermostat without the capability", t, func() {
+		thermostat := &Thermostat{DeviceID: "z1234", CanHeat: true, HvacMode: Heat}
+		Convey("Cool modes should be rejected", func() {
+			err := thermostat.SetHvacMode(Cool)
+			So(err.Description, ShouldEqual, "Thermostat cannot cool")
+			err = thermostat.SetHvacMode(HeatCool)
+			So(err.Error, ShouldEqual, CapabilityError)
+		})
+		Convey("Fan timers should be rejected", func() {
+			err := thermostat.SetFanTimerActive(true)
+			So(err.Description, ShouldEqual, "Thermostat does not have a fan")
+		})
+	})
+}
+
+func TestApplyResponse(t *testing.T) {
+	Convey("When a write succeeds", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.AccessTokenURL = ts.URL
+		client.Authorize()
+		client.Token = Token
+		client.APIURL = ts.URL
+		devices, _ := client.Devices()
+		client.RedirectURL = ts.URL
+		thermostat := devices.Thermostats["z1234"]
+		Convey("The response should be applied to the thermostat", func() {
+			err := thermostat.SetTargetTempC(28.5)
+			So(err, ShouldBeNil)
+			So(thermostat.TargetTemperatureC, ShouldEqual, 28.5)
+			So(thermostat.Name, ShouldEqual, "Bedroom (Main)")
+			So(thermostat.Client, ShouldEqual, client)
+		})
+		Convey("When waiting for the stream, the streamed state should be applied", func() {
+			client.ConfirmTimeout = 2 * time.Second
+			err := thermostat.SetFanTimerActive(false)
+			So(err, ShouldBeNil)
+			So(thermostat.FanTimerActive, ShouldBeFalse)
+			So(thermostat.Name, ShouldEqual, "Entryway")
+		})
+		Convey("When the stream never shows the value we should time out", func() {
+			client.ConfirmTimeout = 200 * time.Millisecond
+			err := thermostat.SetTargetTempC(28.5)
+			So(err.Error, ShouldEqual, "confirm_timeout")
+			So(thermostat.TargetTemperatureC, ShouldEqual, 28.5)
+		})
+	})
+}
+
+func TestThermostatSettings(t *testing.T) {
+	Convey("When changing thermostat settings", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.APIURL = ts.URL
+		devices, _ := client.Devices()
+		client.RedirectURL = ts.URL
+		thermostat := devices.Thermostats["z1234"]
+		Convey("When setting the label", func() {
+			err := thermostat.SetLabel("Guest Room")
+			So(err, ShouldBeNil)
+			So(thermostat.Label, ShouldEqual, "Guest Room")
+		})
+		Convey("When setting the fan timer duration", func() {
+			err := thermostat.SetFanTimerDuration(30)
+			So(err, ShouldBeNil)
+			So(thermostat.FanTimerDuration, ShouldEqual, 30)
+			err = thermostat.SetFanTimerDuration(20)
+			So(err.Error, ShouldEqual, RangeError)
+		})
+		Convey("When setting the temperature scale", func() {
+			err := thermostat.SetTemperatureScale(Celsius)
+			So(err, ShouldBeNil)
+			So(thermostat.TemperatureScale, ShouldEqual, Celsius)
+			err = thermostat.SetTemperatureScale(Scale("K"))
+			So(err.Description, ShouldEqual, "Invalid temperature scale requested - must be F or C")
 		})
 	})
 }
@@ -0,0 +1,100 @@
+package nest
+
+import (
+	"time"
+)
+
+const (
+	// DefaultTripWindow is the width of the arrival window used by a new Trip
+	DefaultTripWindow = 10 * time.Minute
+	// DefaultTripMinChange is how far a new Trip's arrival must move before it is sent again
+	DefaultTripMinChange = time.Minute
+)
+
+// Trip tracks the ETA of one trip to a structure, refreshing the arrival window as the estimate changes
+type Trip struct {
+	ID        string
+	Structure *Structure
+	Window    time.Duration
+	MinChange time.Duration
+	Begin     time.Time
+	End       time.Time
+	active    bool
+}
+
+/*
+NewTrip creates a Trip to the structure with the given trip ID
+
+	trip := structure.NewTrip("phone-1234-" + time.Now().Format("20060102150405"))
+	trip.ArrivingIn(25 * time.Minute)
+*/
+func (s *Structure) NewTrip(tripID string) *Trip {
+	return &Trip{
+		ID:        tripID,
+		Structure: s,
+		Window:    DefaultTripWindow,
+		MinChange: DefaultTripMinChange,
+	}
+}
+
+// Active returns true once the trip has been sent and until it is cancelled
+func (t *Trip) Active() bool {
+	return t.active
+}
+
+// ArrivingIn refreshes the arrival window to start after the given duration from now
+func (t *Trip) ArrivingIn(duration time.Duration) *APIError {
+	return t.Arriving(time.Now().Add(duration))
+}
+
+/*
+Arriving refreshes the arrival window to start at arrival, or now if arrival has passed.
+The first call sets the ETA, later calls update it when the arrival moved by at least MinChange.
+
+	trip.Arriving(time.Now().Add(25 * time.Minute))
+*/
+func (t *Trip) Arriving(arrival time.Time) *APIError {
+	begin := arrival
+	if now := time.Now(); begin.Before(now) {
+		begin = now
+	}
+	end := begin.Add(t.Window)
+	if !t.active {
+		// An arrival due now would fail SetETA's check that it is in the future, so only the window is checked
+		apiErr := checkWindow(begin, end)
+		if apiErr == nil {
+			apiErr = t.Structure.putETA(t.ID, begin, end)
+		}
+		if apiErr != nil {
+			return apiErr
+		}
+		t.active = true
+	} else {
+		change := begin.Sub(t.Begin)
+		if change < 0 {
+			change = -change
+		}
+		if change < t.MinChange {
+			return nil
+		}
+		apiErr := t.Structure.UpdateETA(t.ID, begin, end)
+		if apiErr != nil {
+			return apiErr
+		}
+	}
+	t.Begin, t.End = begin, end
+	return nil
+}
+
+// Cancel cancels the trip if it was sent
+func (t *Trip) Cancel() *APIError {
+	if !t.active {
+		return nil
+	}
+	apiErr := t.Structure.CancelETA(t.ID)
+	if apiErr != nil {
+		return apiErr
+	}
+	t.active = false
+	return nil
+}
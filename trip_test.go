@@ -0,0 +1,58 @@
+package nest
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTrip(t *testing.T) {
+	client := New(ClientID, State, ClientSecret, AuthorizationCode)
+	client.Token = Token
+	client.APIURL = ts.URL
+	structures, _ := client.Structures()
+	structure := structures["h68sn..."]
+
+	Convey("When tracking a trip", t, func() {
+		trip := structure.NewTrip("phone-trip")
+		So(trip.Window, ShouldEqual, DefaultTripWindow)
+		So(trip.Active(), ShouldBeFalse)
+
+		Convey("The first arrival should set the ETA", func() {
+			arrival := time.Now().Add(20 * time.Minute)
+			So(trip.Arriving(arrival), ShouldBeNil)
+			So(trip.Active(), ShouldBeTrue)
+			So(trip.End, ShouldResemble, arrival.Add(DefaultTripWindow))
+			So(structure.ETA.TripID, ShouldEqual, "phone-trip")
+
+			Convey("Small changes should not be sent", func() {
+				So(trip.Arriving(arrival.Add(30*time.Second)), ShouldBeNil)
+				So(trip.Begin, ShouldResemble, arrival)
+			})
+			Convey("Larger changes should update the ETA", func() {
+				So(trip.Arriving(arrival.Add(-5*time.Minute)), ShouldBeNil)
+				So(trip.Begin, ShouldResemble, arrival.Add(-5*time.Minute))
+				So(structure.ETA.EstimatedArrivalWindowBegin, ShouldResemble, arrival.Add(-5*time.Minute))
+			})
+			Convey("Cancelling should clear the ETA", func() {
+				So(trip.Cancel(), ShouldBeNil)
+				So(trip.Active(), ShouldBeFalse)
+				So(structure.ETA, ShouldBeNil)
+			})
+		})
+		Convey("An arrival due now should set the ETA", func() {
+			So(trip.ArrivingIn(0), ShouldBeNil)
+			So(trip.Active(), ShouldBeTrue)
+		})
+		Convey("An arrival already passed should start the window now", func() {
+			start := time.Now()
+			So(trip.Arriving(start.Add(-2*time.Minute)), ShouldBeNil)
+			So(trip.Begin, ShouldHappenOnOrAfter, start)
+			So(trip.End, ShouldResemble, trip.Begin.Add(DefaultTripWindow))
+		})
+		Convey("Cancelling a trip never sent should do nothing", func() {
+			So(trip.Cancel(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package nest
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+/*
+WhereName returns the name of the where with the given where_id, or an empty string if the structure does not have it
+https://developer.nest.com/documentation/api#wheres
+
+	name := structure.WhereName(thermostat.WhereID)
+*/
+func (s *Structure) WhereName(whereID string) string {
+	where, ok := s.Wheres[whereID]
+	if !ok {
+		return ""
+	}
+	return where.Name
+}
+
+/*
+CreateWhere adds a custom where to the structure. If a where with the same name exists it is returned instead.
+https://developer.nest.com/documentation/api#wheres
+
+	where, err := structure.CreateWhere("Upstairs Hallway")
+*/
+func (s *Structure) CreateWhere(name string) (*Where, *APIError) {
+	if strings.TrimSpace(name) == "" {
+		return nil, generateAPIError("The name of a where must not be empty")
+	}
+	for _, where := range s.Wheres {
+		if strings.EqualFold(where.Name, name) {
+			return where, nil
+		}
+	}
+	request := make(map[string]string)
+	request["name"] = name
+	body, _ := json.Marshal(request)
+	response, apiErr := s.Client.send("POST", "/structures/"+s.StructureID+"/wheres.json", body)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	where := &Where{}
+	json.Unmarshal(response, where)
+	if where.WhereID == "" {
+		return nil, generateAPIError("The Nest API did not return a where_id")
+	}
+	if s.Wheres == nil {
+		s.Wheres = make(map[string]*Where)
+	}
+	s.Wheres[where.WhereID] = where
+	return where, nil
+}
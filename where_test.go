@@ -0,0 +1,48 @@
+package nest
+
+import (
+	"encoding/json"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestWheres(t *testing.T) {
+	Convey("Given a structure with wheres", t, func() {
+		combined := &Combined{}
+		json.Unmarshal(combinedJSON(), combined)
+		structure := combined.Structures["VqFabWH21nwVyd4RWgJgNb292wa7hG_dUwo2i2SG7j3-BOLY0BA4sw"]
+		thermostat := combined.Devices.Thermostats["peyiJNo0IldT2YlIVtYaGQ"]
+
+		Convey("A where_id should resolve to its name", func() {
+			So(structure.WhereName(thermostat.WhereID), ShouldEqual, "Hallway")
+			So(structure.WhereName("unknown"), ShouldBeEmpty)
+		})
+	})
+
+	Convey("When creating a where", t, func() {
+		client := New(ClientID, State, ClientSecret, AuthorizationCode)
+		client.Token = Token
+		client.RedirectURL = ts.URL
+		structure := &Structure{StructureID: "s1234", Client: client}
+
+		Convey("The new where should be added to the structure", func() {
+			where, err := structure.CreateWhere("Upstairs Hallway")
+			So(err, ShouldBeNil)
+			So(where.WhereID, ShouldEqual, "w5678")
+			So(where.Name, ShouldEqual, "Upstairs Hallway")
+			So(structure.WhereName("w5678"), ShouldEqual, "Upstairs Hallway")
+		})
+
+		Convey("An existing where should be returned as is", func() {
+			structure.Wheres = map[string]*Where{"w1": {WhereID: "w1", Name: "Kitchen"}}
+			where, err := structure.CreateWhere("kitchen")
+			So(err, ShouldBeNil)
+			So(where.WhereID, ShouldEqual, "w1")
+		})
+
+		Convey("An empty name should be rejected", func() {
+			_, err := structure.CreateWhere(" ")
+			So(err.Description, ShouldEqual, "The name of a where must not be empty")
+		})
+	})
+}